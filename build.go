@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/mrcrowl/swarm/ui"
+	"github.com/mrcrowl/swarm/util"
+)
+
+const buildCommand = "build"
+const defaultBuildOutputPath = "dist"
+
+// runBuild bundles every module in a build once and writes the output to disk.  The build must be named, since this
+// may run unattended
+func runBuild(args []string) {
+	swarmConfig := loadSwarmConfig()
+	runtimeConfig := ui.RequireBuild(buildCommand, swarmConfig.Builds, args)
+	ws, moduleSet := createModuleSet(swarmConfig, runtimeConfig)
+	if *hashFlag {
+		runtimeConfig.HashFilenames = true
+	}
+	if *minifyFlag {
+		runtimeConfig.Minify = true
+	}
+
+	outputPath := *outFlag
+	if outputPath == "" {
+		outputPath = defaultBuildOutputPath
+	}
+
+	fmt.Printf("Writing bundles to '%s'...\n", outputPath)
+	err := moduleSet.WriteBundles(outputPath)
+	util.ExitIfError(err, "Failed to write bundles: %s", err)
+	saveCache(ws)
+
+	failed := false
+	missing := moduleSet.MissingImports()
+	if len(missing) > 0 {
+		moduleNames := make([]string, 0, len(missing))
+		for name := range missing {
+			moduleNames = append(moduleNames, name)
+		}
+		sort.Strings(moduleNames)
+
+		for _, name := range moduleNames {
+			fmt.Printf("Missing imports in module '%s':\n", name)
+			for _, path := range missing[name] {
+				fmt.Printf("   %s\n", path)
+			}
+		}
+		failed = true
+	}
+
+	if loadErrors := moduleSet.LoadErrors(); len(loadErrors) > 0 {
+		fmt.Println("Failed to load:")
+		for _, message := range loadErrors {
+			fmt.Printf("   %s\n", message)
+		}
+		failed = true
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -11,11 +11,13 @@ import (
 
 // Bundler is
 type Bundler struct {
+	order         string
+	mappingsCache *devtools.MappingsCache // the source map chunks of the last bundle, reused for unchanged files
 }
 
-// NewBundler returns a new Bundler
-func NewBundler() *Bundler {
-	return &Bundler{}
+// NewBundler returns a new Bundler, which concatenates files in the given order, e.g. config.BundleOrderTopological
+func NewBundler(order string) *Bundler {
+	return &Bundler{order, devtools.NewMappingsCache()}
 }
 
 // ByFilepath a type to sort files by their names.
@@ -34,10 +36,19 @@ func (b *Bundler) Bundle(fileset *source.FileSet, runtimeConfig *config.RuntimeC
 	var jsBuilder strings.Builder
 	entryPointFilename := path.Base(entryPointPath)
 	mapBuilder := devtools.NewSourceMapBuilder(entryPointFilename, fileset.Count())
+	mapBuilder.UseCache(b.mappingsCache)
+	if runtimeConfig != nil && runtimeConfig.SourcesContent {
+		mapBuilder.EmbedSources()
+	}
 
-	// sort by filepath
-	files := fileset.Files()
-	sort.Sort(ByFilepath(files))
+	var files []*source.File
+	if b.order == config.BundleOrderTopological {
+		files = fileset.FilesInDependencyOrder()
+	} else {
+		// sort by filepath
+		files = fileset.Files()
+		sort.Sort(ByFilepath(files))
+	}
 
 	lastSourceMapLineIndex := 0
 	lineIndex := 0
@@ -50,7 +61,7 @@ func (b *Bundler) Bundle(fileset *source.FileSet, runtimeConfig *config.RuntimeC
 			lineCount++
 			lineIndex++
 		}
-		if sourceMap := file.SourceMap(runtimeConfig, entryPointPath); sourceMap != nil {
+		if sourceMap := file.SourceMap(entryPointPath); sourceMap != nil {
 			spacerLines := lineIndex - lastSourceMapLineIndex - lineCount
 			lastSourceMapLineIndex = lineIndex
 			sourceMap.EnsureLoaded()
@@ -58,34 +69,15 @@ func (b *Bundler) Bundle(fileset *source.FileSet, runtimeConfig *config.RuntimeC
 		}
 	}
 	javascript = jsBuilder.String()
-	sourcemap = mapBuilder.String()
+	if runtimeConfig != nil && runtimeConfig.Minify {
+		var edits []devtools.LineEdit
+		javascript, edits = minifyJavascript(javascript)
+		mapBuilder.EditLines(edits)
+	}
+	if runtimeConfig != nil && runtimeConfig.SourceMapFormat == config.SourceMapFormatIndex {
+		sourcemap = mapBuilder.IndexMap()
+	} else {
+		sourcemap = mapBuilder.String()
+	}
 	return
 }
-
-// func newSourceMapBuilder(filename string) *sourceMapBuilder {
-// 	sb := &strings.Builder{}
-// 	sb.WriteString(`{"version":3,"file":"`)
-// 	sb.WriteString(filename)
-// 	sb.WriteString(`","sections":[`)
-// 	return &sourceMapBuilder{sb, false}
-// }
-
-// func (smb *sourceMapBuilder) String() string {
-// 	smb.sb.WriteString(`]}`)
-// 	return smb.sb.String()
-// }
-
-// func (smb *sourceMapBuilder) WriteSection(line int, column int, sourceMapContents string) {
-// 	if !smb.seenFirst {
-// 		smb.seenFirst = true
-// 	} else {
-// 		smb.sb.WriteString(",")
-// 	}
-// 	smb.sb.WriteString("\n")
-// 	smb.sb.WriteString(`{"offset":{"line":`)
-// 	smb.sb.WriteString(strconv.Itoa(line))
-// 	smb.sb.WriteString(`,"column":`)
-// 	smb.sb.WriteString(strconv.Itoa(column))
-// 	smb.sb.WriteString(`},"map":`)
-// 	smb.sb.WriteString(sourceMapContents) // <-- the actual sourcemap file we're injecting
-// }
@@ -1 +1,71 @@
 package bundle
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/dep"
+	"github.com/mrcrowl/swarm/source"
+	"github.com/mrcrowl/swarm/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBundleOrder(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	testutil.WriteTextFile(workspacePath, "a.js", `System.register(["./z"], function (exports_1, context_1) {
+});`)
+	testutil.WriteTextFile(workspacePath, "z.js", "window.z = true;")
+
+	ws := source.NewWorkspace(workspacePath)
+	fileset := dep.BuildFileSet(ws, "a", nil, map[string]string{})
+	runtimeConfig := config.NewRuntimeConfig("", "")
+
+	cases := map[string]struct {
+		order    string
+		expected []string
+	}{
+		"filepath":    {order: config.BundleOrderFilepath, expected: []string{`"a.js"`, `"z.js"`}},
+		"topological": {order: config.BundleOrderTopological, expected: []string{`"z.js"`, `"a.js"`}},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			javascript, _ := NewBundler(tc.order).Bundle(fileset, runtimeConfig, "a")
+			first := strings.Index(javascript, tc.expected[0])
+			second := strings.Index(javascript, tc.expected[1])
+			assert.True(t, first >= 0 && first < second, "Unexpected bundle order:\n%s", javascript)
+		})
+	}
+}
+
+func TestModuleBundleOrder(t *testing.T) {
+	buildConfig := config.NewRuntimeConfig("", "")
+	buildConfig.BundleOrder = config.BundleOrderTopological
+
+	descr := &config.NormalisedModuleDescription{}
+	assert.Equal(t, config.BundleOrderTopological, bundleOrder(descr, buildConfig))
+
+	descr.BundleOrder = config.BundleOrderFilepath
+	assert.Equal(t, config.BundleOrderFilepath, bundleOrder(descr, buildConfig))
+}
+
+func TestBundleMinified(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	testutil.WriteTextFile(workspacePath, "a.js", `System.register([], function (exports_1, context_1) {
+    alert("hi");
+});
+//# sourceMappingURL=a.js.map`)
+	testutil.WriteTextFile(workspacePath, "a.js.map", `{"version":3,"file":"a.js","sources":["a.ts"],"names":[],"mappings":";IAAA,KAAK;"}`)
+
+	ws := source.NewWorkspace(workspacePath)
+	fileset := dep.BuildFileSet(ws, "a", nil, map[string]string{})
+	runtimeConfig := config.NewRuntimeConfig("", "")
+	runtimeConfig.Minify = true
+
+	javascript, sourcemap := NewBundler(config.BundleOrderFilepath).Bundle(fileset, runtimeConfig, "a")
+	assert.Contains(t, javascript, "\nalert(\"hi\");\n")
+	assert.Contains(t, sourcemap, `"mappings":";AAAA,KAAK`)
+}
@@ -0,0 +1,53 @@
+package bundle
+
+import (
+	"fmt"
+	"github.com/mrcrowl/swarm/source"
+)
+
+// ModuleCycles reports the cycles between modules, caused by circular excludes in the build description
+func (set *ModuleSet) ModuleCycles() *source.CycleReport {
+	return set.moduleCycles
+}
+
+// FileCycles reports the import cycles between files, keyed by module name
+func (set *ModuleSet) FileCycles() map[string]*source.CycleReport {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+
+	reports := make(map[string]*source.CycleReport)
+	for _, mod := range set.modules {
+		if report := mod.fileset.Cycles(); !report.Empty() {
+			reports[mod.Name()] = report
+		}
+	}
+	return reports
+}
+
+// CycleWarnings lists every module and file cycle, including the edges that were removed to break them
+func (set *ModuleSet) CycleWarnings() []string {
+	var warnings []string
+	appendReport := func(description string, report *source.CycleReport) {
+		for _, cycle := range report.Cycles {
+			warnings = append(warnings, fmt.Sprintf("WARNING: %s cycle: %s", description, source.DisplayCycle(cycle)))
+		}
+		for _, edge := range report.BrokenEdges {
+			warnings = append(warnings, fmt.Sprintf("         %s cycle broken by ignoring: %s", description, edge))
+		}
+	}
+
+	appendReport("module", set.ModuleCycles())
+	fileCycles := set.FileCycles()
+	for _, name := range set.names() {
+		if report, found := fileCycles[name]; found {
+			appendReport("import (module '"+name+"')", report)
+		}
+	}
+	return warnings
+}
+
+func (set *ModuleSet) printCycleWarnings() {
+	for _, warning := range set.CycleWarnings() {
+		fmt.Println(warning)
+	}
+}
@@ -0,0 +1,176 @@
+package bundle
+
+import (
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"github.com/mrcrowl/swarm/source"
+	"github.com/mrcrowl/swarm/util"
+)
+
+// Levels of detail for a dependency graph
+const (
+	GraphLevelFiles   = "files"
+	GraphLevelModules = "modules"
+)
+
+// GraphFilter restricts which parts of a ModuleSet are included in a dependency graph
+type GraphFilter struct {
+	Module          string // only include files/modules belonging to this module
+	RootPath        string // only include the subtree of files imported (transitively) by this file
+	CrossModuleOnly bool   // only include edges between different modules
+}
+
+// moduleOwning finds the first module (in topological order) whose fileset contains a file
+func (set *ModuleSet) moduleOwning(id string) *Module {
+	for _, mod := range set.modules {
+		if mod.fileset.Contains(id) {
+			return mod
+		}
+	}
+	return nil
+}
+
+// Graph creates a dependency graph of the files or modules in a ModuleSet
+func (set *ModuleSet) Graph(level string, filter *GraphFilter) *source.DependencyGraph {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+
+	if filter == nil {
+		filter = &GraphFilter{}
+	}
+	if level == GraphLevelModules {
+		return set.moduleGraph(filter)
+	}
+	return set.fileGraph(filter)
+}
+
+func (set *ModuleSet) moduleGraph(filter *GraphFilter) *source.DependencyGraph {
+	graph := source.NewDependencyGraph()
+	for _, mod := range set.modules {
+		if filter.Module != "" && mod.Name() != filter.Module {
+			continue
+		}
+		graph.AddNode(mod.Name(), "")
+		for _, excl := range mod.excludedModules {
+			graph.AddNode(excl.Name(), "")
+			graph.AddEdge(mod.Name(), excl.Name())
+		}
+	}
+	return graph
+}
+
+func (set *ModuleSet) fileGraph(filter *GraphFilter) *source.DependencyGraph {
+	graph := source.NewDependencyGraph()
+
+	ownerName := func(id string) (string, bool) {
+		if owner := set.moduleOwning(id); owner != nil {
+			return owner.Name(), true
+		}
+		return "", false
+	}
+
+	var ids []string
+	if filter.RootPath != "" {
+		if rootID, found := set.resolveFileID(filter.RootPath); found {
+			ids = set.reachableFileIDs(rootID)
+		}
+	} else {
+		for _, mod := range set.modules {
+			for _, file := range mod.fileset.Files() {
+				ids = append(ids, file.ID)
+			}
+		}
+	}
+
+	for _, id := range ids {
+		owner := set.moduleOwning(id)
+		if owner == nil || (filter.Module != "" && owner.Name() != filter.Module) {
+			continue
+		}
+		group := owner.Name()
+		for _, dependencyID := range owner.fileset.DependencyIDs(id) {
+			dependencyGroup, found := ownerName(dependencyID)
+			if !found {
+				continue // missing import
+			}
+			if filter.CrossModuleOnly && dependencyGroup == group {
+				continue
+			}
+			graph.AddNode(id, group)
+			graph.AddNode(dependencyID, dependencyGroup)
+			graph.AddEdge(id, dependencyID)
+		}
+		if !filter.CrossModuleOnly {
+			graph.AddNode(id, group)
+		}
+	}
+	return graph
+}
+
+// resolveFileID finds the ID of a file from a root-relative path, which may or may not include the .js extension
+func (set *ModuleSet) resolveFileID(relativePath string) (string, bool) {
+	relativePath = strings.TrimPrefix(strings.Replace(relativePath, "\\", "/", -1), "/")
+	candidates := []string{relativePath}
+	if path.Ext(relativePath) == ".js" {
+		candidates = append(candidates, util.RemoveExtension(relativePath))
+	}
+
+	for _, id := range candidates {
+		if set.moduleOwning(id) != nil {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// reachableFileIDs lists the IDs of the files imported (transitively) by a file, including the file itself
+func (set *ModuleSet) reachableFileIDs(rootID string) []string {
+	seen := map[string]bool{rootID: true}
+	queue := []string{rootID}
+	for i := 0; i < len(queue); i++ {
+		owner := set.moduleOwning(queue[i])
+		if owner == nil {
+			continue
+		}
+		for _, dependencyID := range owner.fileset.DependencyIDs(queue[i]) {
+			if !seen[dependencyID] {
+				seen[dependencyID] = true
+				queue = append(queue, dependencyID)
+			}
+		}
+	}
+	return queue
+}
+
+// GraphHTTPHandler creates an http.HandlerFunc that exports the dependency graph.
+// Query parameters: format (dot|json|mermaid), level (files|modules), module, root & cross
+func (set *ModuleSet) GraphHTTPHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		format := query.Get("format")
+		if format == "" {
+			format = source.GraphFormatJSON
+		}
+		filter := &GraphFilter{
+			Module:          query.Get("module"),
+			RootPath:        query.Get("root"),
+			CrossModuleOnly: query.Get("cross") == "true",
+		}
+
+		output, err := set.Graph(query.Get("level"), filter).Format(format)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		switch format {
+		case source.GraphFormatJSON:
+			w.Header().Set("Content-Type", "application/json")
+		default:
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		}
+		io.WriteString(w, output)
+	}
+}
@@ -0,0 +1,86 @@
+package bundle
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ManifestFilename is the name of the file that maps entry points to their hashed bundles
+const ManifestFilename = "manifest.json"
+
+// contentHashLength is the number of hex digits of the content hash included in output filenames
+const contentHashLength = 8
+
+// ManifestEntry describes the output files for a single entry point
+type ManifestEntry struct {
+	JS  string `json:"js"`
+	Map string `json:"map,omitempty"`
+}
+
+// contentHash calculates a short hash of a module's bundled javascript and source map
+func contentHash(javascript string, sourceMap string) string {
+	hash := sha256.New()
+	io.WriteString(hash, javascript)
+	io.WriteString(hash, sourceMap)
+	return hex.EncodeToString(hash.Sum(nil))[:contentHashLength]
+}
+
+// Manifest maps each module's primary entry point to its (hashed) output files
+func (set *ModuleSet) Manifest() map[string]*ManifestEntry {
+	manifest := make(map[string]*ManifestEntry, len(set.modules))
+	for _, mod := range set.modules {
+		entry := &ManifestEntry{JS: mod.OutputName() + ".js"}
+		if set.runtimeConfig.SourceMapsEnabled() {
+			entry.Map = mod.OutputName() + ".js.map"
+		}
+		manifest[mod.PrimaryEntryPoint()] = entry
+	}
+	return manifest
+}
+
+// writeManifest writes the manifest.json file into the output path
+func (set *ModuleSet) writeManifest(outputPath string) error {
+	jsonBytes, err := json.MarshalIndent(set.Manifest(), "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(outputPath, os.ModePerm); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(outputPath, ManifestFilename), jsonBytes, 0644)
+}
+
+// HashedHTTPHandler serves the current hashed bundles and source maps, whose URLs change with every build.
+// It returns false for any other request, so that it may be consulted before the static file server
+func (set *ModuleSet) HashedHTTPHandler() func(w http.ResponseWriter, r *http.Request) bool {
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		if !set.runtimeConfig.HashFilenames {
+			return false
+		}
+
+		set.mutex.Lock()
+		defer set.mutex.Unlock()
+
+		urlPath := strings.TrimPrefix(r.URL.Path, "/")
+		for _, mod := range set.modules {
+			switch urlPath {
+			case mod.OutputName() + ".js":
+				io.WriteString(w, mod.javascriptOutput())
+				return true
+			case mod.OutputName() + ".js.map":
+				if set.runtimeConfig.SourceMapsEnabled() {
+					io.WriteString(w, mod.sourceMapOutput())
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,80 @@
+package bundle
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/source"
+	"github.com/mrcrowl/swarm/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createHashedModuleSet(workspacePath string) *ModuleSet {
+	testutil.WriteTextFile(workspacePath, "Config.js", "")
+	srcPath := testutil.MakeSubdirectoryTree(workspacePath, "app/src/ep")
+	testutil.WriteTextFile(srcPath, "App.js", `System.register([], function (exports_1, context_1) {
+});`)
+
+	descr, _ := config.LoadBuildDescriptionString(writeBundlesBuildJSON)
+	runtimeConfig := config.NewRuntimeConfig("", "app")
+	runtimeConfig.HashFilenames = true
+	return CreateModuleSet(source.NewWorkspace(workspacePath), descr.NormaliseModules(workspacePath), runtimeConfig)
+}
+
+func TestWriteBundlesHashed(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	set := createHashedModuleSet(workspacePath)
+
+	outputPath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(outputPath)
+	assert.Nil(t, set.WriteBundles(outputPath))
+
+	var manifest map[string]*ManifestEntry
+	err := json.Unmarshal([]byte(testutil.ReadTextFile(outputPath, ManifestFilename)), &manifest)
+	assert.Nil(t, err)
+
+	entry := manifest["app/src/ep/App"]
+	if assert.NotNil(t, entry) {
+		assert.Regexp(t, `^app/src/ep/App\.[0-9a-f]{8}\.js$`, entry.JS)
+		assert.Equal(t, entry.JS+".map", entry.Map)
+
+		javascript := testutil.ReadTextFile(outputPath, filepath.FromSlash(entry.JS))
+		assert.Contains(t, javascript, "//# sourceMappingURL="+filepath.Base(entry.Map))
+		assert.NotEmpty(t, testutil.ReadTextFile(outputPath, filepath.FromSlash(entry.Map)))
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	assert.Equal(t, contentHash("a", "b"), contentHash("a", "b"))
+	assert.NotEqual(t, contentHash("a", "b"), contentHash("a", "c"))
+	assert.Len(t, contentHash("a", "b"), contentHashLength)
+}
+
+func TestHashedHTTPHandler(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	set := createHashedModuleSet(workspacePath)
+	set.NotifyChanges(nil)
+	mod := set.modules[0]
+	handler := set.HashedHTTPHandler()
+
+	tests := []struct {
+		urlPath  string
+		expected bool
+	}{
+		{"/" + mod.OutputName() + ".js", true},
+		{"/" + mod.OutputName() + ".js.map", true},
+		{"/app/src/ep/App.00000000.js", false},
+		{"/app/index.html", false},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		handled := handler(recorder, httptest.NewRequest("GET", test.urlPath, nil))
+		assert.Equal(t, test.expected, handled, test.urlPath)
+	}
+}
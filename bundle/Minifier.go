@@ -0,0 +1,318 @@
+package bundle
+
+import (
+	"strings"
+	"github.com/mrcrowl/swarm/devtools"
+)
+
+// Kinds of token that affect how the next token may be separated from it
+const (
+	tokenOther = iota
+	tokenNumber
+	tokenRegex
+)
+
+// keywordsBeforeExpression are the keywords after which a '/' begins a regular expression, rather than a division
+var keywordsBeforeExpression = map[string]bool{
+	"return": true, "typeof": true, "instanceof": true, "in": true, "of": true, "new": true, "delete": true,
+	"void": true, "throw": true, "case": true, "do": true, "else": true, "yield": true, "await": true,
+}
+
+// minifier strips comments and whitespace from javascript, one line at a time
+type minifier struct {
+	src          string
+	pos          int
+	lineStart    int
+	line         strings.Builder
+	lines        []string
+	removed      []devtools.ColumnRange
+	edits        []devtools.LineEdit
+	pendingFrom  int  // column at which the current run of whitespace/comments began, or -1
+	keepLine     bool // the line is part of a multi-line string, template or comment, so must be kept even if empty
+	lastChar     byte
+	lastKind     int
+	regexAllowed bool
+	braceDepth   int
+	templates    []int // brace depth at which each enclosing template's ${ expression began
+}
+
+// minifyJavascript strips comments and unnecessary whitespace from javascript.  Lines are never joined, so
+// automatic semicolon insertion is unaffected.  An edit is returned for every input line, describing how it moved
+func minifyJavascript(javascript string) (string, []devtools.LineEdit) {
+	m := &minifier{src: javascript, pendingFrom: -1, regexAllowed: true}
+	m.run()
+
+	minified := strings.Join(m.lines, "\n")
+	if strings.HasSuffix(javascript, "\n") && len(m.lines) > 0 {
+		minified += "\n"
+	}
+	return minified, m.edits
+}
+
+func (m *minifier) run() {
+	n := len(m.src)
+	for m.pos < n {
+		c := m.src[m.pos]
+		next := m.peek(1)
+		switch {
+		case c == '\n':
+			m.endLine()
+		case c == ' ' || c == '\t' || c == '\r' || c == '\v' || c == '\f':
+			m.skipTo(m.pos + 1)
+		case c == '/' && next == '/':
+			end := strings.IndexByte(m.src[m.pos:], '\n')
+			if end < 0 {
+				end = n - m.pos
+			}
+			m.skipTo(m.pos + end)
+		case c == '/' && next == '*' && m.peek(2) == '!':
+			m.emitSpan(m.pos, m.blockCommentEnd(), tokenOther) // preserve license comments
+		case c == '/' && next == '*':
+			m.skipBlockComment()
+		case c == '/' && m.regexAllowed:
+			if end, ok := m.regexEnd(); ok {
+				m.emitSpan(m.pos, end, tokenRegex)
+				m.regexAllowed = false
+			} else {
+				m.emitPunctuation(c)
+			}
+		case c == '\'' || c == '"':
+			m.emitSpan(m.pos, m.stringEnd(c), tokenOther)
+			m.regexAllowed = false
+		case c == '`':
+			m.emitTemplate(m.pos)
+		case c == '}' && len(m.templates) > 0 && m.templates[len(m.templates)-1] == m.braceDepth:
+			m.templates = m.templates[:len(m.templates)-1]
+			m.emitTemplate(m.pos)
+		case isWordByte(c):
+			end := m.pos
+			for end < n && isWordByte(m.src[end]) {
+				end++
+			}
+			word := m.src[m.pos:end]
+			kind := tokenOther
+			if c >= '0' && c <= '9' {
+				kind = tokenNumber
+			}
+			m.emitSpan(m.pos, end, kind)
+			m.regexAllowed = keywordsBeforeExpression[word]
+		default:
+			m.emitPunctuation(c)
+		}
+	}
+
+	if m.line.Len() > 0 || m.keepLine {
+		m.endLine()
+	}
+}
+
+func (m *minifier) peek(offset int) byte {
+	if m.pos+offset < len(m.src) {
+		return m.src[m.pos+offset]
+	}
+	return 0
+}
+
+func (m *minifier) column(pos int) int {
+	return pos - m.lineStart
+}
+
+// skipTo drops the source up to an offset (on the current line), remembering where the dropped run began
+func (m *minifier) skipTo(end int) {
+	if m.pendingFrom < 0 {
+		m.pendingFrom = m.column(m.pos)
+	}
+	m.pos = end
+}
+
+func (m *minifier) skipBlockComment() {
+	end := m.blockCommentEnd()
+	for {
+		newline := strings.IndexByte(m.src[m.pos:end], '\n')
+		if newline < 0 {
+			m.skipTo(end)
+			return
+		}
+		m.skipTo(m.pos + newline)
+		m.endLine()
+	}
+}
+
+func (m *minifier) blockCommentEnd() int {
+	if end := strings.Index(m.src[m.pos+2:], "*/"); end >= 0 {
+		return m.pos + 2 + end + 2
+	}
+	return len(m.src)
+}
+
+// endLine finishes the current line, which must be positioned on a newline (or the end of the source)
+func (m *minifier) endLine() {
+	text := m.line.String()
+	if text != "" || m.keepLine {
+		m.edits = append(m.edits, devtools.LineEdit{Line: len(m.lines), Removed: m.removed})
+		m.lines = append(m.lines, text)
+	} else {
+		m.edits = append(m.edits, devtools.LineEdit{Line: -1})
+	}
+
+	m.line.Reset()
+	m.removed = nil
+	m.pendingFrom = -1
+	m.keepLine = false
+	m.lastChar = 0
+	m.pos++
+	m.lineStart = m.pos
+}
+
+// emitSpan copies the source between two offsets, which may span several lines
+func (m *minifier) emitSpan(start int, end int, kind int) {
+	for first := true; ; first = false {
+		newline := strings.IndexByte(m.src[start:end], '\n')
+		chunkEnd := end
+		if newline >= 0 {
+			chunkEnd = start + newline
+		}
+
+		if first {
+			m.separate(start)
+		}
+		m.write(m.src[start:chunkEnd])
+		if newline < 0 {
+			break
+		}
+
+		m.pos = chunkEnd
+		m.keepLine = true
+		m.endLine()
+		m.keepLine = true
+		start = chunkEnd + 1
+	}
+	m.pos = end
+	m.lastKind = kind
+}
+
+func (m *minifier) emitPunctuation(c byte) {
+	m.emitSpan(m.pos, m.pos+1, tokenOther)
+	switch c {
+	case '{':
+		m.braceDepth++
+	case '}':
+		m.braceDepth--
+	}
+	m.regexAllowed = c != ')' && c != ']' && c != '}'
+}
+
+// emitTemplate copies a template literal, starting at its opening ` or at the } that closes a ${ expression
+func (m *minifier) emitTemplate(start int) {
+	n := len(m.src)
+	i := start + 1
+	for i < n {
+		c := m.src[i]
+		if c == '\\' {
+			i += 2
+			continue
+		}
+		if c == '`' {
+			m.emitSpan(start, i+1, tokenOther)
+			m.regexAllowed = false
+			return
+		}
+		if c == '$' && i+1 < n && m.src[i+1] == '{' {
+			m.emitSpan(start, i+2, tokenOther)
+			m.templates = append(m.templates, m.braceDepth)
+			m.regexAllowed = true
+			return
+		}
+		i++
+	}
+	m.emitSpan(start, n, tokenOther)
+}
+
+// separate drops any pending whitespace before a token, keeping a single space only if the tokens would otherwise merge
+func (m *minifier) separate(start int) {
+	if m.pendingFrom < 0 {
+		return
+	}
+
+	from := m.pendingFrom
+	if m.line.Len() > 0 && m.needsSpace(m.src[start]) {
+		m.line.WriteByte(' ')
+		from++
+	}
+	if to := m.column(start); from < to {
+		m.removed = append(m.removed, devtools.ColumnRange{Start: from, End: to})
+	}
+	m.pendingFrom = -1
+}
+
+func (m *minifier) write(text string) {
+	m.line.WriteString(text)
+	if text != "" {
+		m.lastChar = text[len(text)-1]
+	}
+}
+
+func (m *minifier) needsSpace(next byte) bool {
+	prev := m.lastChar
+	switch {
+	case isWordByte(prev) && isWordByte(next):
+		return true
+	case m.lastKind == tokenRegex && isWordByte(next):
+		return true
+	case m.lastKind == tokenNumber && next == '.':
+		return true
+	case (prev == '+' || prev == '-') && next == prev:
+		return true
+	case prev == '-' && next == '>', prev == '<' && next == '!':
+		return true
+	case prev == '/' && (next == '/' || next == '*'):
+		return true
+	}
+	return false
+}
+
+func (m *minifier) stringEnd(quote byte) int {
+	n := len(m.src)
+	for i := m.pos + 1; i < n; i++ {
+		switch m.src[i] {
+		case '\\':
+			if i+2 < n && m.src[i+1] == '\r' && m.src[i+2] == '\n' {
+				i++
+			}
+			i++
+		case quote:
+			return i + 1
+		case '\n':
+			return i // unterminated
+		}
+	}
+	return n
+}
+
+func (m *minifier) regexEnd() (int, bool) {
+	n := len(m.src)
+	inClass := false
+	for i := m.pos + 1; i < n; i++ {
+		switch c := m.src[i]; {
+		case c == '\n':
+			return 0, false
+		case c == '\\':
+			i++
+		case c == '[':
+			inClass = true
+		case c == ']':
+			inClass = false
+		case c == '/' && !inClass:
+			i++
+			for i < n && isWordByte(m.src[i]) {
+				i++
+			}
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '$' || c == '\\' || c >= 0x80
+}
@@ -0,0 +1,54 @@
+package bundle
+
+import (
+	"testing"
+
+	"github.com/mrcrowl/swarm/devtools"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMinifyJavascript(t *testing.T) {
+	cases := map[string]struct {
+		input    string
+		expected string
+	}{
+		"indentation":       {input: "function f() {\n    return a + b;\n}\n", expected: "function f(){\nreturn a+b;\n}\n"},
+		"blank lines":       {input: "a();\n\n\n\tb();\n", expected: "a();\nb();\n"},
+		"line comments":     {input: "a(); // call a\n// whole line\nb();", expected: "a();\nb();"},
+		"block comments":    {input: "a(/* x */1);\n/* one\n   two */\nb();", expected: "a(1);\nb();"},
+		"license comments":  {input: "/*! keep me */\na();", expected: "/*! keep me */\na();"},
+		"strings":           {input: `x = "a  // b" + 'c /* d */';`, expected: `x="a  // b"+'c /* d */';`},
+		"keywords":          {input: "var  a = typeof b;", expected: "var a=typeof b;"},
+		"plus plus":         {input: "a + +b - -c; d++ + e;", expected: "a+ +b- -c;d++ +e;"},
+		"regex":             {input: "x = /ab+c\\/ [/]/g .test(s);", expected: "x=/ab+c\\/ [/]/g.test(s);"},
+		"regex after word":  {input: "return /a b/ instanceof RegExp;", expected: "return/a b/ instanceof RegExp;"},
+		"division":          {input: "x = a / b / c;", expected: "x=a/b/c;"},
+		"number dot":        {input: "x = 1 .toString();", expected: "x=1 .toString();"},
+		"template":          {input: "x = `a  ${ b + `c  d` }\n    e`;", expected: "x=`a  ${b+`c  d`}\n    e`;"},
+		"template braces":   {input: "x = `${ {a: 1}.a }  `;", expected: "x=`${{a:1}.a}  `;"},
+		"string continued":  {input: "x = 'a\\\n    b';", expected: "x='a\\\n    b';"},
+		"asi preserved":     {input: "a = b\n(c)\n", expected: "a=b\n(c)\n"},
+		"comment only":      {input: "// nothing\n", expected: ""},
+		"trailing spaces":   {input: "a();   \n", expected: "a();\n"},
+		"crlf":              {input: "a();\r\nb();\r\n", expected: "a();\nb();\n"},
+		"unterminated":      {input: "x = 'abc\ny();", expected: "x='abc\ny();"},
+		"arrow and compare": {input: "f = (a) => a <= b && c !== d;", expected: "f=(a)=>a<=b&&c!==d;"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			minified, _ := minifyJavascript(tc.input)
+			assert.Equal(t, tc.expected, minified)
+		})
+	}
+}
+
+func TestMinifyJavascriptEdits(t *testing.T) {
+	_, edits := minifyJavascript("a();\n\n  // comment\n  b( 1 );\n")
+	assert.Equal(t, []devtools.LineEdit{
+		{Line: 0},
+		{Line: -1},
+		{Line: -1},
+		{Line: 1, Removed: []devtools.ColumnRange{{Start: 0, End: 2}, {Start: 4, End: 5}, {Start: 6, End: 7}}},
+	}, edits)
+}
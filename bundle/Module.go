@@ -18,6 +18,7 @@ type Module struct {
 	excludedModules   []*Module
 	bundledJavascript string
 	bundledSourcemap  string
+	contentHash       string
 	bundler           *Bundler
 	runtimeConfig     *config.RuntimeConfig
 }
@@ -31,11 +32,22 @@ func NewModule(ws *source.Workspace, descr *config.NormalisedModuleDescription,
 		entryPoints:       entryPoints,
 		excludedModules:   nil,
 		bundledJavascript: "",
-		bundler:           NewBundler(),
+		bundler:           NewBundler(bundleOrder(descr, runtimeConfig)),
 		runtimeConfig:     runtimeConfig,
 	}
 }
 
+// bundleOrder chooses the order of files within a module's bundle, preferring the module's own setting over the build's
+func bundleOrder(descr *config.NormalisedModuleDescription, runtimeConfig *config.RuntimeConfig) string {
+	if descr.BundleOrder != "" {
+		return descr.BundleOrder
+	}
+	if runtimeConfig != nil {
+		return runtimeConfig.BundleOrder
+	}
+	return config.BundleOrderFilepath
+}
+
 // GetFileByPath returns the file with the specified path, if it exists
 func (mod *Module) GetFileByPath(path string) *source.File {
 	return mod.fileset.Get(path)
@@ -48,9 +60,24 @@ func (mod *Module) Name() string {
 
 // SourceMapName gets the name to associate with this module's source map
 func (mod *Module) SourceMapName() string {
+	if mod.hashFilenames() {
+		return path.Base(mod.OutputName()) + ".js.map"
+	}
 	return path.Base(mod.Name()) + ".js.map"
 }
 
+// OutputName gets the path of the module's bundle (without extension), which includes the content hash when enabled
+func (mod *Module) OutputName() string {
+	if mod.hashFilenames() {
+		return mod.PrimaryEntryPoint() + "." + mod.contentHash
+	}
+	return mod.PrimaryEntryPoint()
+}
+
+func (mod *Module) hashFilenames() bool {
+	return mod.runtimeConfig != nil && mod.runtimeConfig.HashFilenames
+}
+
 func (mod *Module) dirty() bool {
 	return mod.fileset.Dirty()
 }
@@ -96,10 +123,31 @@ func (mod *Module) absorbChanges(changes *monitor.EventChangeset) {
 
 func (mod *Module) generateBundle() {
 	mod.bundledJavascript, mod.bundledSourcemap = mod.bundler.Bundle(mod.fileset, mod.runtimeConfig, mod.PrimaryEntryPoint())
+	mod.contentHash = contentHash(mod.bundledJavascript, mod.sourceMapOutput())
 	mod.fileset.ClearDirty()
 	fmt.Printf("   Bundled: /%s.js (%d files)\n", mod.PrimaryEntryPoint(), mod.fileset.Count())
 }
 
+// javascriptOutput gets the bundled javascript, with a trailing reference to the module's source map
+func (mod *Module) javascriptOutput() string {
+	return mod.bundledJavascript + fmt.Sprintf("//# sourceMappingURL=%s", mod.SourceMapName())
+}
+
+// sourceMapOutput gets the bundled source map
+func (mod *Module) sourceMapOutput() string {
+	return mod.bundledSourcemap
+}
+
+// MissingImports gets the paths imported by this module that could not be found in the workspace
+func (mod *Module) MissingImports() []string {
+	return mod.fileset.MissingPaths()
+}
+
+// LoadErrors gets the errors which prevented files in this module from loading
+func (mod *Module) LoadErrors() []error {
+	return mod.fileset.LoadErrors()
+}
+
 func (mod *Module) links() []string {
 	links := make([]string, len(mod.excludedModules))
 	for i, mod := range mod.excludedModules {
@@ -1,22 +1,29 @@
 package bundle
 
 import (
-	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
-	"strings"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
 	"github.com/mrcrowl/swarm/config"
 	"github.com/mrcrowl/swarm/monitor"
 	"github.com/mrcrowl/swarm/source"
 	"sync"
 )
 
+// maxConcurrentModules limits the number of modules that are absorbing changes or bundling at any one time
+var maxConcurrentModules = runtime.NumCPU()
+
 // ModuleSet is
 type ModuleSet struct {
 	modules       []*Module
 	mutex         *sync.Mutex
 	runtimeConfig *config.RuntimeConfig
+	moduleCycles  *source.CycleReport
 }
 
 // CreateModuleSet creates a ModuleSet from a list of NormalisedModuleDescriptions
@@ -28,8 +35,9 @@ func CreateModuleSet(ws *source.Workspace, moduleDescriptions []*config.Normalis
 	}
 
 	set := &ModuleSet{
-		modules: modules,
-		mutex:   &sync.Mutex{},
+		modules:       modules,
+		mutex:         &sync.Mutex{},
+		runtimeConfig: runtimeConfig,
 	}
 
 	for _, mod := range set.modules {
@@ -47,25 +55,136 @@ func CreateModuleSet(ws *source.Workspace, moduleDescriptions []*config.Normalis
 
 // NotifyChanges absorbs an EventChangeset, triggering artefacts to be recompiled, when necessary
 func (set *ModuleSet) NotifyChanges(changes *monitor.EventChangeset) {
+	if changes == nil {
+		set.printCycleWarnings()
+	}
+
 	set.mutex.Lock()
 	if changes != nil {
-		for _, mod := range set.modules {
+		// excluded modules must finish absorbing changes before the modules that exclude them
+		set.forEachModuleConcurrently(true, func(mod *Module) {
 			mod.absorbChanges(changes)
-		}
+		})
 	}
 
-	// TODO: could this be parallelised?
-	for _, mod := range set.modules {
+	var bundledMutex sync.Mutex
+	didBundle := false
+	set.forEachModuleConcurrently(false, func(mod *Module) {
 		if mod.dirty() {
 			mod.generateBundle()
-			if changes != nil {
-				changes.FlagDidBundle()
-			}
+			bundledMutex.Lock()
+			didBundle = true
+			bundledMutex.Unlock()
 		}
+	})
+	if didBundle && changes != nil {
+		changes.FlagDidBundle()
 	}
 	set.mutex.Unlock()
 }
 
+// forEachModuleConcurrently calls fn for each module, using a pool of (at most) maxConcurrentModules workers.
+// When waitForExcludes is true, a module is only processed once its excluded modules are done.
+func (set *ModuleSet) forEachModuleConcurrently(waitForExcludes bool, fn func(mod *Module)) {
+	done := make(map[*Module]chan bool, len(set.modules))
+	position := make(map[*Module]int, len(set.modules))
+	for i, mod := range set.modules {
+		done[mod] = make(chan bool)
+		position[mod] = i
+	}
+
+	// modules are sorted topologically, so waiting only on earlier modules can't deadlock, even for circular excludes
+	waitsFor := make(map[*Module][]*Module, len(set.modules))
+	if waitForExcludes {
+		for _, mod := range set.modules {
+			for _, excl := range mod.excludedModules {
+				if position[excl] < position[mod] {
+					waitsFor[mod] = append(waitsFor[mod], excl)
+				} else if excl != mod {
+					waitsFor[excl] = append(waitsFor[excl], mod)
+				}
+			}
+		}
+	}
+
+	workers := make(chan bool, maxConcurrentModules)
+	var wg sync.WaitGroup
+	for _, mod := range set.modules {
+		wg.Add(1)
+		go func(mod *Module) {
+			defer wg.Done()
+			defer close(done[mod])
+			for _, dependency := range waitsFor[mod] {
+				<-done[dependency]
+			}
+
+			workers <- true
+			fn(mod)
+			<-workers
+		}(mod)
+	}
+	wg.Wait()
+}
+
+// WriteBundles bundles every module once and writes the javascript and source maps beneath outputPath,
+// along with a manifest.json when filenames are hashed
+func (set *ModuleSet) WriteBundles(outputPath string) error {
+	set.printCycleWarnings()
+
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+
+	for _, mod := range set.modules {
+		mod.generateBundle()
+
+		jsFilepath := filepath.Join(outputPath, filepath.FromSlash(mod.OutputName()+".js"))
+		if err := os.MkdirAll(filepath.Dir(jsFilepath), os.ModePerm); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(jsFilepath, []byte(mod.javascriptOutput()), 0644); err != nil {
+			return err
+		}
+
+		if set.runtimeConfig.SourceMapsEnabled() {
+			if err := ioutil.WriteFile(jsFilepath+".map", []byte(mod.sourceMapOutput()), 0644); err != nil {
+				return err
+			}
+		}
+	}
+
+	if set.runtimeConfig.HashFilenames {
+		return set.writeManifest(outputPath)
+	}
+	return nil
+}
+
+// MissingImports gets the imports that could not be found, keyed by module name
+func (set *ModuleSet) MissingImports() map[string][]string {
+	missing := make(map[string][]string)
+	for _, mod := range set.modules {
+		if paths := mod.MissingImports(); len(paths) > 0 {
+			missing[mod.Name()] = paths
+		}
+	}
+	return missing
+}
+
+// LoadErrors gets the errors which prevented files from loading in any module, e.g. a failed transform
+func (set *ModuleSet) LoadErrors() []string {
+	seen := make(map[string]bool)
+	var messages []string
+	for _, mod := range set.modules {
+		for _, err := range mod.LoadErrors() {
+			if message := err.Error(); !seen[message] {
+				seen[message] = true
+				messages = append(messages, message)
+			}
+		}
+	}
+	sort.Strings(messages)
+	return messages
+}
+
 // FindFileByPath finds and returns a file by path name
 func (set *ModuleSet) FindFileByPath(path string) *source.File {
 	for _, mod := range set.modules {
@@ -76,6 +195,22 @@ func (set *ModuleSet) FindFileByPath(path string) *source.File {
 	return nil
 }
 
+// FindInjectedStylesheets finds the stylesheets, in any module, that must be injected again when the stylesheet at path
+// changes, including those which inline it with @import
+func (set *ModuleSet) FindInjectedStylesheets(path string) []*source.File {
+	seen := make(map[string]bool)
+	var stylesheets []*source.File
+	for _, mod := range set.modules {
+		for _, file := range mod.fileset.InjectedStylesheets(path) {
+			if !seen[file.ID] {
+				seen[file.ID] = true
+				stylesheets = append(stylesheets, file)
+			}
+		}
+	}
+	return stylesheets
+}
+
 func (set *ModuleSet) getModule(name string) *Module {
 	for _, mod := range set.modules {
 		if mod.description.Name == name {
@@ -105,6 +240,7 @@ func (set *ModuleSet) linksMap() map[string][]string {
 
 func (set *ModuleSet) sort() {
 	sortedModules := make([]*Module, len(set.modules))
+	set.moduleCycles = source.AnalyseCycles(set.linksMap(), set.names())
 	graph := source.NewIDGraph(set.linksMap())
 	sortedNames := graph.SortTopologically(set.names())
 	for i, name := range sortedNames {
@@ -117,16 +253,13 @@ func (set *ModuleSet) sort() {
 func (set *ModuleSet) GenerateHTTPHandlers() map[string]http.HandlerFunc {
 	createJSHandler := func(module *Module) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
-			io.WriteString(w, module.bundledJavascript)
-			io.WriteString(w, fmt.Sprintf("//# sourceMappingURL=%s", module.SourceMapName()))
+			io.WriteString(w, module.javascriptOutput())
 		}
 	}
 
 	createMapHandler := func(module *Module) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
-			sourceMap := module.bundledSourcemap
-			sourceMap = strings.Replace(sourceMap, `["BaseController.ts"]`, `["ui/base/BaseController.ts"]`, 1)
-			io.WriteString(w, sourceMap)
+			io.WriteString(w, module.sourceMapOutput())
 		}
 	}
 
@@ -1,12 +1,16 @@
 package bundle
 
 import (
+	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/monitor"
 	"github.com/mrcrowl/swarm/source"
 	"github.com/mrcrowl/swarm/testutil"
 
+	"github.com/rjeczalik/notify"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -62,3 +66,294 @@ func TestCreateModuleSet(t *testing.T) {
 // 	set := CreateModuleSet(createWorkspace(), descr.NormaliseModules("c:\\wf\\lp\\web\\App"), nil)
 // 	assert.Equal(t, "controlPanel/ControlPanel", set.names()[0], "controlPanel/ControlPanel should be the first module")
 // }
+
+const writeBundlesBuildJSON = `{
+	"modules": [
+		{
+			"name": "ep/App"
+		}
+	],
+	"base": "app/src/"
+}`
+
+func TestWriteBundles(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	testutil.WriteTextFile(workspacePath, "Config.js", "")
+	srcPath := testutil.MakeSubdirectoryTree(workspacePath, "app/src/ep")
+	testutil.WriteTextFile(srcPath, "App.js", `System.register(["./Present", "./Absent"], function (exports_1, context_1) {
+});`)
+	testutil.WriteTextFile(srcPath, "Present.js", `System.register([], function (exports_1, context_1) {
+});`)
+
+	descr, err := config.LoadBuildDescriptionString(writeBundlesBuildJSON)
+	assert.Nil(t, err)
+	set := CreateModuleSet(source.NewWorkspace(workspacePath), descr.NormaliseModules(workspacePath), config.NewRuntimeConfig("", "app"))
+
+	outputPath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(outputPath)
+	err = set.WriteBundles(outputPath)
+	assert.Nil(t, err)
+
+	bundleDir := filepath.Join(outputPath, "app", "src", "ep")
+	javascript := testutil.ReadTextFile(bundleDir, "App.js")
+	assert.Contains(t, javascript, `System.register("app/src/ep/Present.js"`)
+	assert.Contains(t, javascript, "//# sourceMappingURL=App.js.map")
+	assert.NotEmpty(t, testutil.ReadTextFile(bundleDir, "App.js.map"))
+
+	missing := set.MissingImports()
+	assert.Equal(t, map[string][]string{"ep/App": {"app/src/ep/Absent"}}, missing)
+}
+
+const graphBuildJSON = `{
+	"modules": [
+		{
+			"name": "lib/Lib"
+		},
+		{
+			"name": "ep/App",
+			"exclude": [
+				"lib/Lib"
+			]
+		}
+	],
+	"base": "app/src/"
+}`
+
+func createGraphModuleSet(workspacePath string) *ModuleSet {
+	testutil.WriteTextFile(workspacePath, "Config.js", "")
+	epPath := testutil.MakeSubdirectoryTree(workspacePath, "app/src/ep")
+	libPath := testutil.MakeSubdirectoryTree(workspacePath, "app/src/lib")
+	testutil.WriteTextFile(epPath, "App.js", `System.register(["./Util", "../lib/Lib"], function (exports_1, context_1) {
+});`)
+	testutil.WriteTextFile(epPath, "Util.js", `System.register(["../lib/Lib"], function (exports_1, context_1) {
+});`)
+	testutil.WriteTextFile(libPath, "Lib.js", `System.register([], function (exports_1, context_1) {
+});`)
+
+	descr, _ := config.LoadBuildDescriptionString(graphBuildJSON)
+	return CreateModuleSet(source.NewWorkspace(workspacePath), descr.NormaliseModules(workspacePath), config.NewRuntimeConfig("", "app"))
+}
+
+func TestGraph(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	set := createGraphModuleSet(workspacePath)
+
+	files := set.Graph(GraphLevelFiles, nil)
+	assert.Equal(t, []string{"app/src/ep/App", "app/src/ep/Util", "app/src/lib/Lib"}, files.Nodes())
+	assert.Equal(t, []string{"app/src/ep/Util", "app/src/lib/Lib"}, files.Edges("app/src/ep/App"))
+	assert.Equal(t, "lib/Lib", files.Group("app/src/lib/Lib"))
+
+	cross := set.Graph(GraphLevelFiles, &GraphFilter{CrossModuleOnly: true})
+	assert.Equal(t, []string{"app/src/lib/Lib"}, cross.Edges("app/src/ep/App"))
+	assert.Equal(t, []string{"app/src/lib/Lib"}, cross.Edges("app/src/ep/Util"))
+
+	subtree := set.Graph(GraphLevelFiles, &GraphFilter{RootPath: "app/src/ep/Util.js"})
+	assert.Equal(t, []string{"app/src/ep/Util", "app/src/lib/Lib"}, subtree.Nodes())
+
+	module := set.Graph(GraphLevelFiles, &GraphFilter{Module: "lib/Lib"})
+	assert.Equal(t, []string{"app/src/lib/Lib"}, module.Nodes())
+
+	modules := set.Graph(GraphLevelModules, nil)
+	assert.Equal(t, []string{"ep/App", "lib/Lib"}, modules.Nodes())
+	assert.Equal(t, []string{"lib/Lib"}, modules.Edges("ep/App"))
+}
+
+func TestWhy(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	set := createGraphModuleSet(workspacePath)
+
+	inclusions, err := set.Why("app/src/lib/Lib.js")
+	assert.Nil(t, err)
+	assert.Len(t, inclusions, 1)
+	assert.Equal(t, "lib/Lib", inclusions[0].Module)
+	assert.Equal(t, [][]string{{"app/src/lib/Lib"}}, inclusions[0].Chains)
+
+	inclusions, err = set.Why("app/src/ep/Util")
+	assert.Nil(t, err)
+	assert.Len(t, inclusions, 1)
+	assert.Equal(t, [][]string{{"app/src/ep/App", "app/src/ep/Util"}}, inclusions[0].Chains)
+	assert.Empty(t, inclusions[0].Absorbers)
+
+	_, err = set.Why("app/src/ep/Missing")
+	assert.NotNil(t, err)
+}
+
+func TestWhyAbsorbers(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	testutil.WriteTextFile(workspacePath, "Config.js", "")
+	srcPath := testutil.MakeSubdirectoryTree(workspacePath, "app/src")
+	testutil.WriteTextFile(srcPath, "One.js", `System.register(["./Shared"], function (exports_1, context_1) {
+});`)
+	testutil.WriteTextFile(srcPath, "Two.js", `System.register(["./Shared"], function (exports_1, context_1) {
+});`)
+	testutil.WriteTextFile(srcPath, "Shared.js", `System.register([], function (exports_1, context_1) {
+});`)
+
+	descr, _ := config.LoadBuildDescriptionString(`{"modules": [{"name": "One"}, {"name": "Two"}], "base": "app/src/"}`)
+	set := CreateModuleSet(source.NewWorkspace(workspacePath), descr.NormaliseModules(workspacePath), config.NewRuntimeConfig("", "app"))
+
+	inclusions, err := set.Why("app/src/Shared")
+	assert.Nil(t, err)
+	assert.Len(t, inclusions, 2)
+	byModule := map[string]*Inclusion{}
+	for _, inclusion := range inclusions {
+		byModule[inclusion.Module] = inclusion
+	}
+	assert.Equal(t, [][]string{{"app/src/One", "app/src/Shared"}}, byModule["One"].Chains)
+	assert.Equal(t, []string{"Two"}, byModule["One"].Absorbers)
+	assert.Equal(t, []string{"One"}, byModule["Two"].Absorbers)
+}
+
+const circularBuildJSON = `{
+	"modules": [
+		{
+			"name": "abcd/efgh",
+			"exclude": [
+				"wxyz/zzzz"
+			]
+		},
+		{
+			"name": "wxyz/zzzz",
+			"exclude": [
+				"abcd/efgh"
+			]
+		}
+	],
+	"base": "app/src/"
+}`
+
+func TestCycleWarnings(t *testing.T) {
+	descr, err := config.LoadBuildDescriptionString(circularBuildJSON)
+	assert.Nil(t, err)
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	testutil.WriteTextFile(workspacePath, "Config.js", "")
+	set := CreateModuleSet(source.NewWorkspace(workspacePath), descr.NormaliseModules(workspacePath), config.NewRuntimeConfig("", ""))
+
+	expected := []string{
+		"WARNING: module cycle: abcd/efgh → wxyz/zzzz → abcd/efgh",
+		"         module cycle broken by ignoring: wxyz/zzzz → abcd/efgh",
+	}
+	assert.Equal(t, expected, set.CycleWarnings())
+}
+
+func TestNotifyChanges(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	set := createGraphModuleSet(workspacePath)
+	set.NotifyChanges(nil)
+
+	app := set.getModule("ep/App")
+	lib := set.getModule("lib/Lib")
+	assert.False(t, app.dirty())
+	assert.False(t, lib.dirty())
+
+	libFilepath := testutil.WriteTextFile(filepath.Join(workspacePath, "app", "src", "lib"), "Lib.js", `System.register([], function (exports_1, context_1) {
+	var changed = true;
+});`)
+	changes := monitor.NewEventChangeset()
+	changes.Add(notify.Write, libFilepath)
+	set.NotifyChanges(changes)
+
+	assert.Contains(t, lib.bundledJavascript, "var changed = true;")
+	assert.NotContains(t, app.bundledJavascript, "var changed = true;")
+	assert.False(t, changes.SkipHotReload())
+}
+
+func TestForEachModuleConcurrentlyWaitsForExcludes(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	testutil.WriteTextFile(workspacePath, "Config.js", "")
+	descr, _ := config.LoadBuildDescriptionString(buildDescrSampleJSON)
+	set := CreateModuleSet(source.NewWorkspace(workspacePath), descr.NormaliseModules(workspacePath), config.NewRuntimeConfig("", ""))
+
+	var mutex sync.Mutex
+	var order []string
+	set.forEachModuleConcurrently(true, func(mod *Module) {
+		mutex.Lock()
+		order = append(order, mod.Name())
+		mutex.Unlock()
+	})
+	assert.Equal(t, []string{"abcd/efgh", "wxyz/zzzz", "stuv/vvvv"}, order)
+}
+
+// createSourceMapModuleSet creates a module with a file whose source map maps "alert" on its second line, and a file
+// whose source map is invalid
+func createSourceMapModuleSet(workspacePath string, runtimeConfig *config.RuntimeConfig) *ModuleSet {
+	testutil.WriteTextFile(workspacePath, "Config.js", "")
+	srcPath := testutil.MakeSubdirectoryTree(workspacePath, "app/src/ep")
+	testutil.WriteTextFile(srcPath, "App.js", `System.register(["./Broken"], function (exports_1, context_1) {
+    alert("hi");
+});
+//# sourceMappingURL=App.js.map`)
+	testutil.WriteTextFile(srcPath, "App.js.map", `{"version":3,"file":"App.js","sources":["App.ts"],"names":[],"mappings":";IAAA,KAAK;AACL"}`)
+	testutil.WriteTextFile(srcPath, "App.ts", "alert(\"hi\");")
+	testutil.WriteTextFile(srcPath, "Broken.js", `System.register([], function (exports_1, context_1) {
+});
+//# sourceMappingURL=Broken.js.map`)
+	testutil.WriteTextFile(srcPath, "Broken.js.map", "{")
+
+	descr, _ := config.LoadBuildDescriptionString(writeBundlesBuildJSON)
+	return CreateModuleSet(source.NewWorkspace(workspacePath), descr.NormaliseModules(workspacePath), runtimeConfig)
+}
+
+func TestSourceMapLookupAndVerify(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	set := createSourceMapModuleSet(workspacePath, config.NewRuntimeConfig("", "app"))
+
+	position, err := set.LookupSourcePosition("ep/App", 1, 9)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "App.ts", position.Source)
+		assert.Equal(t, 5, position.Column)
+	}
+	_, err = set.LookupSourcePosition("ep/Missing", 1, 9)
+	assert.NotNil(t, err)
+
+	reports, err := set.VerifySourceMaps("app/src/ep/App.js")
+	assert.Nil(t, err)
+	if assert.Len(t, reports, 1) {
+		assert.Equal(t, "ep/App", reports[0].Module)
+		if assert.Len(t, reports[0].Problems, 2) {
+			assert.Contains(t, reports[0].Problems[0], "app/src/ep/Broken: Invalid JSON in source map")
+			assert.Contains(t, reports[0].Problems[1], "line 2 is out of range for 'App.ts' (1 lines)")
+		}
+	}
+}
+
+func TestVerifySourceMapsInvalidUpstreamMap(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	testutil.WriteTextFile(workspacePath, "Config.js", "")
+	srcPath := testutil.MakeSubdirectoryTree(workspacePath, "app/src/ep")
+	testutil.WriteTextFile(srcPath, "App.js", `System.register(["./Broken"], function (exports_1, context_1) {
+    alert("hi");
+});
+//# sourceMappingURL=App.js.map`)
+	// a segment with only a generated column is valid, but has no source
+	testutil.WriteTextFile(srcPath, "App.js.map", `{"version":3,"file":"App.js","sources":["App.ts"],"names":[],"mappings":"A;A,IAAA,KAAK"}`)
+	testutil.WriteTextFile(srcPath, "App.ts", "alert(\"hi\");")
+	testutil.WriteTextFile(srcPath, "Broken.js", `System.register([], function (exports_1, context_1) {
+});
+//# sourceMappingURL=Broken.js.map`)
+	testutil.WriteTextFile(srcPath, "Broken.js.map", `{"version":3,"file":"Broken.js","sources":["Broken.ts"],"names":[],"mappings":"!!"}`)
+
+	descr, _ := config.LoadBuildDescriptionString(writeBundlesBuildJSON)
+	set := CreateModuleSet(source.NewWorkspace(workspacePath), descr.NormaliseModules(workspacePath), config.NewRuntimeConfig("", "app"))
+
+	reports, err := set.VerifySourceMaps("ep/App")
+	assert.Nil(t, err)
+	if assert.Len(t, reports, 1) && assert.Len(t, reports[0].Problems, 1) {
+		assert.Contains(t, reports[0].Problems[0], "app/src/ep/Broken: invalid source map 'Broken.js.map': invalid character '!' in mappings at offset 0")
+	}
+
+	position, err := set.LookupSourcePosition("ep/App", 1, 9)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "App.ts", position.Source)
+		assert.Equal(t, 5, position.Column)
+	}
+}
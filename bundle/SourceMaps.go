@@ -0,0 +1,108 @@
+package bundle
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"github.com/mrcrowl/swarm/devtools"
+	"github.com/mrcrowl/swarm/source"
+)
+
+// SourceMapReport lists the problems found with a module's source map
+type SourceMapReport struct {
+	Module   string
+	Problems []string
+}
+
+// LookupSourcePosition bundles the module with an entry point, then finds the original position of a (zero-based)
+// line and column of its bundle, using its source map
+func (set *ModuleSet) LookupSourcePosition(entry string, line int, column int) (*devtools.OriginalPosition, error) {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+
+	mod := set.findModule(entry)
+	if mod == nil {
+		return nil, fmt.Errorf("'%s' is not the name or entry point of a module", entry)
+	}
+	if mod.dirty() {
+		mod.generateBundle()
+	}
+	sourceMap, err := devtools.ParseBundleSourceMap(mod.sourceMapOutput())
+	if err != nil {
+		return nil, err
+	}
+	position, found := sourceMap.Lookup(line, column)
+	if !found {
+		return nil, fmt.Errorf("%d:%d of '%s' is not mapped to a source", line+1, column+1, mod.PrimaryEntryPoint()+".js")
+	}
+	return position, nil
+}
+
+// VerifySourceMaps bundles the module with an entry point (or every module, when entry is empty), then checks that
+// each segment of its source map points to a valid source and line, and that its files' own source maps could be loaded
+func (set *ModuleSet) VerifySourceMaps(entry string) ([]*SourceMapReport, error) {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+
+	modules := set.modules
+	if entry != "" {
+		mod := set.findModule(entry)
+		if mod == nil {
+			return nil, fmt.Errorf("'%s' is not the name or entry point of a module", entry)
+		}
+		modules = []*Module{mod}
+	}
+
+	reports := make([]*SourceMapReport, len(modules))
+	for i, mod := range modules {
+		reports[i] = &SourceMapReport{mod.Name(), mod.verifySourceMap()}
+	}
+	return reports, nil
+}
+
+// findModule finds a module by its name, or the path of its primary entry point (with or without .js)
+func (set *ModuleSet) findModule(entry string) *Module {
+	entry = strings.TrimPrefix(strings.Replace(entry, "\\", "/", -1), "/")
+	for _, mod := range set.modules {
+		if mod.Name() == entry || mod.PrimaryEntryPoint() == entry || mod.PrimaryEntryPoint()+".js" == entry {
+			return mod
+		}
+	}
+	return nil
+}
+
+// bundledSourceMapConfig bundles the module, if necessary, and parses its source map
+func (mod *Module) bundledSourceMapConfig() (*source.MapConfig, error) {
+	if mod.dirty() {
+		mod.generateBundle()
+	}
+	return source.ParseSourceMapConfig(mod.sourceMapOutput())
+}
+
+// verifySourceMap describes the problems with the module's source map, and the source maps of its files
+func (mod *Module) verifySourceMap() []string {
+	sourceMap, err := mod.bundledSourceMapConfig()
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var problems []string
+	lineCounts := make(map[string]int)
+	files := mod.fileset.Files()
+	sort.Sort(ByFilepath(files))
+	for _, file := range files {
+		mapping := file.SourceMap(mod.PrimaryEntryPoint())
+		if mapping == nil {
+			continue
+		}
+		mapping.EnsureLoaded()
+		if err := mapping.LoadError(); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", file.ID, err))
+			continue
+		}
+		if contents, found := mapping.SourceContent(); found {
+			lineCounts[mapping.RelativePath()] = strings.Count(contents, "\n") + 1
+		}
+	}
+	return append(problems, devtools.VerifySourceMap(sourceMap, lineCounts)...)
+}
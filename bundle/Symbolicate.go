@@ -0,0 +1,90 @@
+package bundle
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"github.com/mrcrowl/swarm/devtools"
+)
+
+// maxStackTraceBytes limits the size of a stack trace posted to the SymbolicateHTTPHandler
+const maxStackTraceBytes = 1 << 20
+
+// stackFrameLocationPattern matches the location of a frame in a stack trace, e.g. http://localhost:8096/app/src/ep/App.js:1234:56
+// in Chrome's "at fn (...)" or Firefox's "fn@..." format.  The groups are the origin, path, line and column
+var stackFrameLocationPattern = regexp.MustCompile(`(https?://[^/\s()@]+)?(/[^\s()@]*?\.js):(\d+):(\d+)`)
+
+// Symbolicate rewrites the locations in a stack trace that refer to a module's bundle, e.g. /app/src/ep/App.js:1234:56,
+// as the locations in the original sources that generated them, using the bundle's source map.  Lines and columns are
+// one-based, as they are in stack traces.  Locations which can't be mapped are left as they are
+func (set *ModuleSet) Symbolicate(stack string) string {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+
+	sourceMaps := make(map[*Module]*devtools.BundleSourceMap)
+	return stackFrameLocationPattern.ReplaceAllStringFunc(stack, func(location string) string {
+		match := stackFrameLocationPattern.FindStringSubmatch(location)
+		origin, bundlePath := match[1], match[2]
+		line, _ := strconv.Atoi(match[3])
+		column, _ := strconv.Atoi(match[4])
+
+		mod := set.findBundle(strings.TrimSuffix(strings.TrimPrefix(bundlePath, "/"), ".js"))
+		if mod == nil || mod.bundledSourcemap == "" {
+			return location
+		}
+		sourceMap, found := sourceMaps[mod]
+		if !found {
+			sourceMap, _ = devtools.ParseBundleSourceMap(mod.sourceMapOutput())
+			sourceMaps[mod] = sourceMap
+		}
+		if sourceMap == nil {
+			return location
+		}
+
+		position, found := sourceMap.Lookup(line-1, column-1)
+		if !found {
+			return location
+		}
+		sourceURL := position.Source
+		if !strings.Contains(sourceURL, "://") && !strings.HasPrefix(sourceURL, "/") {
+			sourceURL = path.Join(path.Dir(bundlePath), sourceURL) // sources are relative to the bundle
+		}
+		return fmt.Sprintf("%s%s:%d:%d", origin, sourceURL, position.Line+1, position.Column+1)
+	})
+}
+
+// findBundle finds the module whose bundle is served at a root-relative path (without .js), which may include a hash
+func (set *ModuleSet) findBundle(outputName string) *Module {
+	for _, mod := range set.modules {
+		if mod.PrimaryEntryPoint() == outputName || mod.OutputName() == outputName {
+			return mod
+		}
+	}
+	return nil
+}
+
+// SymbolicateHTTPHandler creates an http.HandlerFunc that symbolicates a stack trace POSTed as text, responding with the
+// stack trace rewritten to refer to the original sources
+func (set *ModuleSet) SymbolicateHTTPHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Stack traces must be POSTed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		stack, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxStackTraceBytes))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, set.Symbolicate(string(stack)))
+	}
+}
@@ -0,0 +1,67 @@
+package bundle
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSymbolicate(t *testing.T) {
+	cases := map[string]struct {
+		format string
+		stack  string
+		expect string
+	}{
+		"chrome": {
+			format: config.SourceMapFormatFlattened,
+			stack:  "Error: hi\n    at alert (http://localhost:8096/app/src/ep/App.js:2:10)\n    at http://localhost:8096/lib.js:1:1",
+			expect: "Error: hi\n    at alert (http://localhost:8096/app/src/ep/App.ts:1:6)\n    at http://localhost:8096/lib.js:1:1",
+		},
+		"firefox": {
+			format: config.SourceMapFormatFlattened,
+			stack:  "alert@/app/src/ep/App.js:2:5\n",
+			expect: "alert@/app/src/ep/App.ts:1:1\n",
+		},
+		"index map": {
+			format: config.SourceMapFormatIndex,
+			stack:  "at alert (/app/src/ep/App.js:2:10)",
+			expect: "at alert (/app/src/ep/App.ts:1:6)",
+		},
+		"unmapped": {
+			format: config.SourceMapFormatFlattened,
+			stack:  "at /app/src/ep/App.js:1:1",
+			expect: "at /app/src/ep/App.js:1:1",
+		},
+	}
+	for name, tc := range cases {
+		workspacePath := testutil.CreateTempDir()
+		runtimeConfig := config.NewRuntimeConfig("", "app")
+		runtimeConfig.SourceMapFormat = tc.format
+		set := createSourceMapModuleSet(workspacePath, runtimeConfig)
+		set.NotifyChanges(nil)
+		assert.Equal(t, tc.expect, set.Symbolicate(tc.stack), name)
+		testutil.RemoveTempDir(workspacePath)
+	}
+}
+
+func TestSymbolicateHTTPHandler(t *testing.T) {
+	workspacePath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(workspacePath)
+	set := createSourceMapModuleSet(workspacePath, config.NewRuntimeConfig("", "app"))
+	set.NotifyChanges(nil)
+	handler := set.SymbolicateHTTPHandler()
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest("POST", "/__swarm__/symbolicate", strings.NewReader("at /app/src/ep/App.js:2:10")))
+	assert.Equal(t, 200, recorder.Code)
+	assert.Equal(t, "at /app/src/ep/App.ts:1:6", recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest("GET", "/__swarm__/symbolicate", nil))
+	assert.Equal(t, 405, recorder.Code)
+}
@@ -0,0 +1,111 @@
+package bundle
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Inclusion explains why a file is included in a module
+type Inclusion struct {
+	Module    string
+	Chains    [][]string // shortest import chains from the module's entry points to the file
+	Absorbers []string   // other modules containing the file, which would absorb it if they were excluded
+}
+
+// Why explains which modules include a file, and through which chains of imports
+func (set *ModuleSet) Why(relativePath string) ([]*Inclusion, error) {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+
+	id, found := set.resolveFileID(relativePath)
+	if !found {
+		return nil, fmt.Errorf("'%s' is not included in any module", relativePath)
+	}
+
+	var inclusions []*Inclusion
+	for _, mod := range set.modules {
+		if !mod.fileset.Contains(id) {
+			continue
+		}
+
+		inclusions = append(inclusions, &Inclusion{
+			Module:    mod.Name(),
+			Chains:    mod.importChains(id),
+			Absorbers: set.absorbers(mod, id),
+		})
+	}
+	return inclusions, nil
+}
+
+// absorbers lists the other modules that contain a file, but are not excluded from a module
+func (set *ModuleSet) absorbers(mod *Module, id string) []string {
+	var names []string
+	for _, other := range set.modules {
+		if other == mod || !other.fileset.Contains(id) || mod.excludes(other) || other.excludes(mod) {
+			continue
+		}
+		names = append(names, other.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// excludes tests whether a module directly excludes another module
+func (mod *Module) excludes(other *Module) bool {
+	for _, excl := range mod.excludedModules {
+		if excl == other {
+			return true
+		}
+	}
+	return false
+}
+
+// importChains lists every shortest chain of imports from each of the module's entry points to a file
+func (mod *Module) importChains(id string) [][]string {
+	// walk backwards from the file, recording the distance to each of its (transitive) dependents
+	distances := map[string]int{id: 0}
+	queue := []string{id}
+	for i := 0; i < len(queue); i++ {
+		current := queue[i]
+		for _, dependentID := range mod.fileset.DependentIDs(current) {
+			if _, seen := distances[dependentID]; !seen {
+				distances[dependentID] = distances[current] + 1
+				queue = append(queue, dependentID)
+			}
+		}
+	}
+
+	// walk forwards from each entry point, only ever stepping one closer to the file
+	var chains [][]string
+	var recurse func(chain []string)
+	recurse = func(chain []string) {
+		current := chain[len(chain)-1]
+		if current == id {
+			chains = append(chains, append([]string(nil), chain...))
+			return
+		}
+		for _, dependencyID := range mod.fileset.DependencyIDs(current) {
+			if distance, found := distances[dependencyID]; found && distance == distances[current]-1 {
+				recurse(append(chain, dependencyID))
+			}
+		}
+	}
+
+	for _, entryPoint := range mod.allEntryPoints() {
+		if _, found := distances[entryPoint]; found {
+			recurse([]string{entryPoint})
+		}
+	}
+	return chains
+}
+
+// allEntryPoints gets the primary entry point, followed by any included entry points
+func (mod *Module) allEntryPoints() []string {
+	entryPoints := []string{mod.PrimaryEntryPoint()}
+	for _, entryPoint := range mod.entryPoints {
+		if entryPoint != mod.PrimaryEntryPoint() {
+			entryPoints = append(entryPoints, entryPoint)
+		}
+	}
+	return entryPoints
+}
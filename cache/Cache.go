@@ -0,0 +1,200 @@
+package cache
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const cacheFilename = "cache.json"
+
+// Cache is a persistent, on-disk store of values derived from files.  Values are
+// keyed by filepath and kind, and are discarded once the file's size or modification
+// time changes.  A nil *Cache is valid, and never contains anything.
+type Cache struct {
+	dirpath  string
+	version  string
+	settings map[string]string
+	entries  map[string]*entry
+	dirty    bool
+	mutex    *sync.Mutex
+}
+
+// entry holds the values cached for a single file
+type entry struct {
+	Size    int64                      `json:"size"`
+	ModTime int64                      `json:"modTime"`
+	Values  map[string]json.RawMessage `json:"values"`
+	touched bool
+}
+
+// cacheFile is the JSON structure of the file that the Cache is persisted in
+type cacheFile struct {
+	Version  string            `json:"version"`
+	Settings map[string]string `json:"settings"`
+	Entries  map[string]*entry `json:"entries"`
+}
+
+// Open loads the Cache stored in a directory.  If it is missing, unreadable or was
+// written by a different version of swarm, an empty Cache is returned instead.
+func Open(dirpath string, version string) *Cache {
+	cache := &Cache{
+		dirpath:  dirpath,
+		version:  version,
+		settings: make(map[string]string),
+		entries:  make(map[string]*entry),
+		mutex:    &sync.Mutex{},
+	}
+
+	bytes, err := ioutil.ReadFile(filepath.Join(dirpath, cacheFilename))
+	if err != nil {
+		return cache
+	}
+
+	var stored cacheFile
+	if err := json.Unmarshal(bytes, &stored); err != nil {
+		log.Printf("Ignoring invalid build cache: %s", err)
+		return cache
+	}
+
+	if stored.Version == version && stored.Entries != nil {
+		cache.entries = stored.Entries
+		if stored.Settings != nil {
+			cache.settings = stored.Settings
+		}
+	}
+	return cache
+}
+
+// RequireSetting discards every cached value if a setting differs from the one the Cache was built with
+func (cache *Cache) RequireSetting(key string, value string) {
+	if cache == nil {
+		return
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if existing, found := cache.settings[key]; !found || existing != value {
+		cache.entries = make(map[string]*entry)
+		cache.settings[key] = value
+		cache.dirty = true
+	}
+}
+
+// Get reads a cached value into value, returning false if there is no valid value for the file
+func (cache *Cache) Get(path string, kind string, value interface{}) bool {
+	if cache == nil {
+		return false
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	ent := cache.validEntry(path, info)
+	if ent == nil {
+		return false
+	}
+
+	raw, found := ent.Values[kind]
+	if !found {
+		return false
+	}
+	return json.Unmarshal(raw, value) == nil
+}
+
+// Put stores a value for a file
+func (cache *Cache) Put(path string, kind string, value interface{}) {
+	if cache == nil {
+		return
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	ent := cache.validEntry(path, info)
+	if ent == nil {
+		ent = &entry{
+			Size:    info.Size(),
+			ModTime: info.ModTime().UnixNano(),
+			Values:  make(map[string]json.RawMessage),
+			touched: true,
+		}
+		cache.entries[path] = ent
+	}
+	ent.Values[kind] = raw
+	cache.dirty = true
+}
+
+// validEntry gets the entry for a file, discarding it if the file has changed since it was cached
+func (cache *Cache) validEntry(path string, info os.FileInfo) *entry {
+	ent, found := cache.entries[path]
+	if !found {
+		return nil
+	}
+
+	if ent.Size != info.Size() || ent.ModTime != info.ModTime().UnixNano() {
+		delete(cache.entries, path)
+		cache.dirty = true
+		return nil
+	}
+
+	ent.touched = true
+	return ent
+}
+
+// Save writes the Cache to disk, dropping entries for files that haven't been used since it was opened
+func (cache *Cache) Save() error {
+	if cache == nil {
+		return nil
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if !cache.dirty {
+		return nil
+	}
+
+	for path, ent := range cache.entries {
+		if !ent.touched {
+			delete(cache.entries, path)
+		}
+	}
+
+	bytes, err := json.Marshal(&cacheFile{cache.version, cache.settings, cache.entries})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(cache.dirpath, os.ModePerm); err != nil {
+		return err
+	}
+
+	// write to a temporary file first, so that an interrupted save can't corrupt the cache
+	cacheFilepath := filepath.Join(cache.dirpath, cacheFilename)
+	tempFilepath := cacheFilepath + ".tmp"
+	if err := ioutil.WriteFile(tempFilepath, bytes, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tempFilepath, cacheFilepath); err != nil {
+		return err
+	}
+
+	cache.dirty = false
+	return nil
+}
@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mrcrowl/swarm/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type cachedValue struct {
+	Names []string
+}
+
+func TestGetPut(t *testing.T) {
+	tempDir := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(tempDir)
+	path := testutil.WriteTextFile(tempDir, "a.js", "abc")
+
+	cache := Open(filepath.Join(tempDir, ".swarm-cache"), "1.0.0")
+	var value cachedValue
+	assert.False(t, cache.Get(path, "names", &value))
+
+	cache.Put(path, "names", &cachedValue{[]string{"x", "y"}})
+	assert.True(t, cache.Get(path, "names", &value))
+	assert.Equal(t, []string{"x", "y"}, value.Names)
+	assert.False(t, cache.Get(path, "other", &value))
+}
+
+func TestGetAfterFileChanged(t *testing.T) {
+	tempDir := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(tempDir)
+	path := testutil.WriteTextFile(tempDir, "a.js", "abc")
+
+	cache := Open(filepath.Join(tempDir, ".swarm-cache"), "1.0.0")
+	cache.Put(path, "names", &cachedValue{[]string{"x"}})
+
+	testutil.WriteTextFile(tempDir, "a.js", "abcdef")
+	var value cachedValue
+	assert.False(t, cache.Get(path, "names", &value))
+
+	cache.Put(path, "names", &cachedValue{[]string{"x"}})
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(path, later, later)
+	assert.False(t, cache.Get(path, "names", &value))
+}
+
+func TestSaveAndOpen(t *testing.T) {
+	tempDir := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(tempDir)
+	path := testutil.WriteTextFile(tempDir, "a.js", "abc")
+	cacheDir := filepath.Join(tempDir, ".swarm-cache")
+
+	cache := Open(cacheDir, "1.0.0")
+	cache.RequireSetting("values", "a=1")
+	cache.Put(path, "names", &cachedValue{[]string{"x"}})
+	assert.Nil(t, cache.Save())
+
+	tests := []struct {
+		name     string
+		version  string
+		setting  string
+		expected bool
+	}{
+		{"same", "1.0.0", "a=1", true},
+		{"new version", "1.0.1", "a=1", false},
+		{"new setting", "1.0.0", "a=2", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reopened := Open(cacheDir, test.version)
+			reopened.RequireSetting("values", test.setting)
+			var value cachedValue
+			assert.Equal(t, test.expected, reopened.Get(path, "names", &value))
+		})
+	}
+}
+
+func TestSaveDropsUnusedEntries(t *testing.T) {
+	tempDir := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(tempDir)
+	pathA := testutil.WriteTextFile(tempDir, "a.js", "abc")
+	pathB := testutil.WriteTextFile(tempDir, "b.js", "def")
+	cacheDir := filepath.Join(tempDir, ".swarm-cache")
+
+	cache := Open(cacheDir, "1.0.0")
+	cache.Put(pathA, "names", &cachedValue{[]string{"a"}})
+	cache.Put(pathB, "names", &cachedValue{[]string{"b"}})
+	assert.Nil(t, cache.Save())
+
+	var value cachedValue
+	reopened := Open(cacheDir, "1.0.0")
+	assert.True(t, reopened.Get(pathA, "names", &value))
+	reopened.Put(pathA, "other", &cachedValue{})
+	assert.Nil(t, reopened.Save())
+
+	final := Open(cacheDir, "1.0.0")
+	assert.True(t, final.Get(pathA, "names", &value))
+	assert.False(t, final.Get(pathB, "names", &value))
+}
+
+func TestNilCache(t *testing.T) {
+	var cache *Cache
+	var value cachedValue
+	cache.Put("a.js", "names", &cachedValue{})
+	cache.RequireSetting("values", "a=1")
+	assert.False(t, cache.Get("a.js", "names", &value))
+	assert.Nil(t, cache.Save())
+}
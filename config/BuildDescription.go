@@ -16,9 +16,10 @@ type BuildDescription struct {
 
 // ModuleDescription describes a single module within a systemjs_build file
 type ModuleDescription struct {
-	Name    string   `json:"name"`
-	Include []string `json:"include"`
-	Exclude []string `json:"exclude"`
+	Name        string   `json:"name"`
+	Include     []string `json:"include"`
+	Exclude     []string `json:"exclude"`
+	BundleOrder string   `json:"bundleOrder"` // overrides the build's bundleOrder, when specified
 }
 
 // NormalisedModuleDescription is a module that has paths normalised relative to the root of the workspace
@@ -81,9 +82,10 @@ func (module *ModuleDescription) Normalise(basePath string, rootPath string) *No
 
 	return &NormalisedModuleDescription{
 		ModuleDescription{
-			Name:    module.Name,
-			Include: includes,
-			Exclude: excludes,
+			Name:        module.Name,
+			Include:     includes,
+			Exclude:     excludes,
+			BundleOrder: module.BundleOrder,
 		},
 		relativePath,
 		absoluteFilepath,
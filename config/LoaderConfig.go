@@ -0,0 +1,7 @@
+package config
+
+// LoaderConfig chooses the loader for files matching an extension (e.g. ".svg") or a glob (e.g. "assets/**/*.png")
+type LoaderConfig struct {
+	Match  string `json:"match"`
+	Loader string `json:"loader"` // one of js, css, json, text, dataURI or raw, or the name of a custom loader
+}
@@ -10,3 +10,22 @@ type MonitorConfig struct {
 func NewMonitorConfig(extensions []string, debounceMillis uint) *MonitorConfig {
 	return &MonitorConfig{extensions, debounceMillis}
 }
+
+// WithExtensions creates a copy of the MonitorConfig which also watches the given extensions, e.g. those handled by
+// the configured loaders
+func (mc *MonitorConfig) WithExtensions(extensions []string) *MonitorConfig {
+	merged := append([]string(nil), mc.Extensions...)
+	for _, extension := range extensions {
+		found := false
+		for _, existing := range merged {
+			if existing == extension {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, extension)
+		}
+	}
+	return NewMonitorConfig(merged, mc.DebounceMillis)
+}
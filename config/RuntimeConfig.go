@@ -1,16 +1,38 @@
 package config
 
+// The orders in which files may be concatenated within a bundle
+const (
+	// BundleOrderFilepath sorts files by their filepath (the default)
+	BundleOrderFilepath = "filepath"
+	// BundleOrderTopological sorts files so that each file's dependencies appear before it
+	BundleOrderTopological = "topological"
+)
+
+// The formats in which a bundle's source map may be written
+const (
+	// SourceMapFormatFlattened combines the mappings of every file into a single mappings string (the default)
+	SourceMapFormatFlattened = "flattened"
+	// SourceMapFormatIndex writes an index map, with a section for each file's own source map
+	SourceMapFormatIndex = "index"
+)
+
 // RuntimeConfig describes the expected state at runtime (currently, just what the base path will be)
 type RuntimeConfig struct {
 	// BaseHref gets the expected base path at runtime, e.g. <base href="app" /> ==> "app"
 	BuildPath               string `json:"path"`
 	BaseHref                string `json:"baseHref"`
+	BundleOrder             string `json:"bundleOrder"`
+	HashFilenames           bool   `json:"hashFilenames"`       // name bundles <entry>.<hash>.js, and write a manifest.json
+	Minify                  bool   `json:"minify"`              // strip comments and whitespace from bundles
+	InlineCSSAssetBytes     int    `json:"inlineCSSAssetBytes"` // inline files up to this size from CSS url()s as data URIs
+	SourcesContent          bool   `json:"sourcesContent"`      // embed the original sources in source maps
+	SourceMapFormat         string `json:"sourceMapFormat"`
 	pathInterpolationValues map[string]string
 }
 
 // NewRuntimeConfig creates a RuntimeConfig
 func NewRuntimeConfig(buildPath string, baseHref string) *RuntimeConfig {
-	return &RuntimeConfig{buildPath, baseHref, map[string]string{}}
+	return &RuntimeConfig{buildPath, baseHref, BundleOrderFilepath, false, false, 0, false, SourceMapFormatFlattened, map[string]string{}}
 }
 
 // SourceMapsEnabled ...
@@ -0,0 +1,8 @@
+package config
+
+// SourceRewriteConfig rewrites the sources of the bundled source maps which match a regular expression, e.g.
+// {"match": "^(ui/.*)\\.jsx?$", "replace": "$1.tsx"}.  Sources are matched as root-relative paths, e.g. ui/base/Base.ts
+type SourceRewriteConfig struct {
+	Match   string `json:"match"`
+	Replace string `json:"replace"` // may refer to the groups of the match, e.g. $1
+}
@@ -16,6 +16,7 @@ const swarmConfigDefaultFilename = "swarm.json"
 const defaultRootPathWindows = "%s\\web\\App"
 const defaultRootPathMacOSAndLinux = "%s/web/App" // <-- %s will be replaced with user dir
 const defaultServerPort uint16 = 8096
+const defaultCachePath = ".swarm-cache"
 
 var defaultMonitorExtensions = []string{".js", ".html", ".css", ".json"}
 var defaultBuilds = map[string]*RuntimeConfig{
@@ -31,10 +32,14 @@ var defaultBuilds = map[string]*RuntimeConfig{
 
 // SwarmConfig is the root configuration file
 type SwarmConfig struct {
-	RootPath string                    `json:"root"`
-	Monitor  *MonitorConfig            `json:"monitor"`
-	Builds   map[string]*RuntimeConfig `json:"builds"`
-	Server   *ServerConfig             `json:"server"`
+	RootPath       string                    `json:"root"`
+	Monitor        *MonitorConfig            `json:"monitor"`
+	Builds         map[string]*RuntimeConfig `json:"builds"`
+	Server         *ServerConfig             `json:"server"`
+	Cache          string                    `json:"cache"`          // directory for the persistent build cache
+	Loaders        []*LoaderConfig           `json:"loaders"`        // take precedence over the default loaders, in order
+	Transforms     []*TransformConfig        `json:"transforms"`     // take precedence over the loaders, in order
+	SourceRewrites []*SourceRewriteConfig    `json:"sourceRewrites"` // the first which matches a source map's source applies
 }
 
 func (config *SwarmConfig) expandAndNormalisePaths(cwd string) {
@@ -47,6 +52,7 @@ func (config *SwarmConfig) expandAndNormalisePaths(cwd string) {
 	}
 
 	config.RootPath = norm(cwd, config.RootPath)
+	config.Cache = norm(cwd, config.Cache)
 	for _, b := range config.Builds {
 		b.BuildPath = norm(config.RootPath, b.BuildPath)
 	}
@@ -70,6 +76,10 @@ func (config *SwarmConfig) backfillWithDefaults(cwd string) {
 	if config.Server == nil {
 		config.Server = defaults.Server
 	}
+
+	if config.Cache == "" {
+		config.Cache = defaultCachePath
+	}
 }
 
 // TryLoadSwarmConfigFromCWD tries to load a swarm.json configuration from the current working directory
@@ -110,6 +120,7 @@ func DefaultSwarmConfig(cwd string) *SwarmConfig {
 		Monitor:  NewMonitorConfig(defaultMonitorExtensions, 150),
 		Builds:   defaultBuilds,
 		Server:   NewServerConfig(defaultServerPort, true, true),
+		Cache:    defaultCachePath,
 	}
 	config.expandAndNormalisePaths(cwd)
 	return config
@@ -0,0 +1,10 @@
+package config
+
+// TransformConfig pipes files matching an extension or glob through an external command before they are loaded,
+// e.g. {"match": "**/*.scss", "command": "sass {in}", "output": "css"}
+type TransformConfig struct {
+	Match         string `json:"match"`
+	Command       string `json:"command"`       // run by the shell, with {in} replaced by the file's path, and its contents on stdin
+	Output        string `json:"output"`        // the loader for the command's output, e.g. css or js
+	TimeoutMillis uint   `json:"timeoutMillis"` // defaults to 30 seconds
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const cyclesCommand = "cycles"
+
+// runCycles lists the import cycles between files and modules in a build
+func runCycles(args []string) {
+	_, _, _, moduleSet := loadModuleSet(args)
+
+	warnings := moduleSet.CycleWarnings()
+	if len(warnings) == 0 {
+		fmt.Println("No cycles found")
+		return
+	}
+
+	for _, warning := range warnings {
+		fmt.Println(warning)
+	}
+	os.Exit(1)
+}
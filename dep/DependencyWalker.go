@@ -3,6 +3,8 @@ package dep
 import (
 	"fmt"
 	"path"
+	"runtime"
+	"sort"
 	"strings"
 	"github.com/mrcrowl/swarm/source"
 	"github.com/mrcrowl/swarm/util"
@@ -40,9 +42,17 @@ func UpdateFileset(fileset *source.FileSet, modifiedFileRelativePath string, exc
 		fileID = fileID + ".js"
 		file = fileset.Get(fileID)
 	}
+	if file == nil && !didRemoveJSSuffix {
+		// maybe it's transformed into javascript, e.g. a .ts file, so is known by its path without extension
+		fileID = util.RemoveExtension(modifiedFileRelativePath)
+		file = fileset.Get(fileID)
+	}
 
 	if file != nil {
 		file.UnloadContents()
+		for _, importer := range fileset.CSSImporters(fileID) {
+			importer.UnloadContents() // stylesheets which inline the file with @import
+		}
 		fileset.MarkDirty()
 
 		// 2. update the dependencies (but include "fileset" in the exclusions, so we don't follow paths we already know about)
@@ -51,6 +61,15 @@ func UpdateFileset(fileset *source.FileSet, modifiedFileRelativePath string, exc
 	}
 }
 
+// maxConcurrentReads limits the number of files being read and parsed at any one time
+var maxConcurrentReads = 4 * runtime.NumCPU()
+
+// followResult is the outcome of reading the dependencies of a single import
+type followResult struct {
+	importPath   string
+	dependencies []*source.Import // root-relative
+}
+
 func followDependencyChain(
 	workspace *source.Workspace,
 	entryFileRelativePath string,
@@ -58,7 +77,7 @@ func followDependencyChain(
 	interpolationValues map[string]string,
 ) ([]*source.Import, []*source.DependencyLink) {
 	queue := newImportQueue()
-	links := make([]*source.DependencyLink, 0, 2048)
+	linkIndex := make(map[string][]string)
 
 	entryFileRelativePath = strings.Replace(entryFileRelativePath, "\\", "/", -1)
 	queue.pushPath(entryFileRelativePath)
@@ -75,25 +94,47 @@ func followDependencyChain(
 		return true
 	}
 
-	follow := func(imp *source.Import) {
+	// follow is called concurrently, so it must not touch the queue or links
+	follow := func(imp *source.Import) *followResult {
 		var file *source.File
 		var err error
 
 		importPath := imp.Path()
+		result := &followResult{importPath: importPath}
 		if file, err = workspace.ReadSourceFile(imp); err != nil {
 			fmt.Println("MISSING: " + importPath)
 			// println("Could not find " + rootRelativeDepPath)
-			return
+			return result
 		}
 
-		var dependencyIDs []string
 		for _, dep := range readDependencies(file, interpolationValues) {
 			if dep.IsSolo {
 				continue
 			}
 
-			depRootRelative := imp.ToRootRelativeImport(dep)
+			result.dependencies = append(result.dependencies, imp.ToRootRelativeImport(dep))
+		}
+		return result
+	}
 
+	// the queue, seen-index and links are only touched by this goroutine
+	results := make(chan *followResult)
+	pending := 0
+	for queue.nonEmpty() || pending > 0 {
+		for queue.nonEmpty() && pending < maxConcurrentReads {
+			if ok, imp := queue.pop(); ok {
+				pending++
+				go func(imp *source.Import) {
+					results <- follow(imp)
+				}(imp)
+			}
+		}
+
+		result := <-results
+		pending--
+
+		var dependencyIDs []string
+		for _, depRootRelative := range result.dependencies {
 			if shouldEnqueue(depRootRelative) {
 				queue.push(depRootRelative)
 			}
@@ -102,29 +143,36 @@ func followDependencyChain(
 		}
 
 		if len(dependencyIDs) > 0 {
-			link := source.NewDependencyLink(importPath, dependencyIDs)
-			links = append(links, link)
+			linkIndex[result.importPath] = dependencyIDs
 		}
 	}
 
-	for queue.nonEmpty() {
-		if ok, relativePath := queue.pop(); ok {
-			follow(relativePath)
-		}
+	return queue.outputImports(), sortedLinks(linkIndex)
+}
+
+// sortedLinks creates DependencyLinks in order of their IDs, so that the result doesn't depend on the order files were read
+func sortedLinks(linkIndex map[string][]string) []*source.DependencyLink {
+	ids := make([]string, 0, len(linkIndex))
+	for id := range linkIndex {
+		ids = append(ids, id)
 	}
+	sort.Strings(ids)
 
-	return queue.outputImports(), links
+	links := make([]*source.DependencyLink, len(ids))
+	for i, id := range ids {
+		links[i] = source.NewDependencyLink(id, linkIndex[id])
+	}
+	return links
 }
 
 func readDependencies(file *source.File, interpValues map[string]string) []*source.Import {
-	var line string
-	var err error
-	if line, err = util.ReadFirstLine(file.Filepath); err != nil {
-		return nil
+	dependencies, ok, err := file.RegisterDependencies()
+	if err != nil {
+		fmt.Printf("WARNING: %s\n", err)
 	}
 
 	var filteredDeps []*source.Import
-	if dependencies, ok := source.ParseRegisterDependencies(line, true); ok {
+	if ok {
 		filteredDeps = make([]*source.Import, 0, len(dependencies))
 		for _, dependencyImportPath := range dependencies {
 			dependencyImport := source.NewImportWithInterpolation(dependencyImportPath, interpValues)
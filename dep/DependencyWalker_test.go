@@ -1,9 +1,13 @@
 package dep
 
 import (
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/mrcrowl/swarm/config"
 	"github.com/mrcrowl/swarm/source"
 	"github.com/mrcrowl/swarm/testutil"
-	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -30,3 +34,73 @@ func TestReadDependencies(t *testing.T) {
 	dependencies := readDependencies(file, map[string]string{})
 	assert.Len(t, dependencies, 3)
 }
+
+func TestFollowDependencyChainConcurrently(t *testing.T) {
+	temppath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(temppath)
+	srcPath := testutil.MakeSubdirectoryTree(temppath, "app/src")
+	testutil.WriteTextFile(srcPath, "App.js", `System.register(["./One", "./Two", "tslib"], function (exports_1, context_1) {`)
+	testutil.WriteTextFile(srcPath, "One.js", `System.register(["./Three", "./Two"], function (exports_1, context_1) {`)
+	testutil.WriteTextFile(srcPath, "Two.js", `System.register(["./Three"], function (exports_1, context_1) {`)
+	testutil.WriteTextFile(srcPath, "Three.js", `System.register([], function (exports_1, context_1) {`)
+
+	ws := source.NewWorkspace(temppath)
+	walk := func() ([]string, map[string][]string) {
+		imports, links := followDependencyChain(ws, "app/src/App", nil, map[string]string{})
+		var paths []string
+		for _, imp := range imports {
+			paths = append(paths, imp.Path())
+		}
+		fileset := source.NewFileSet(imports, links, ws)
+		dependencies := map[string][]string{}
+		for _, path := range paths {
+			if ids := fileset.DependencyIDs(path); ids != nil {
+				dependencies[path] = ids
+			}
+		}
+		return paths, dependencies
+	}
+
+	expectedPaths := []string{"app/src/App", "app/src/One", "app/src/Two", "app/src/Three"}
+	expectedDependencies := map[string][]string{
+		"app/src/App": {"app/src/One", "app/src/Two"},
+		"app/src/One": {"app/src/Three", "app/src/Two"},
+		"app/src/Two": {"app/src/Three"},
+	}
+
+	paths, dependencies := walk()
+	assert.ElementsMatch(t, expectedPaths, paths)
+	assert.Equal(t, expectedDependencies, dependencies)
+
+	defer func(n int) { maxConcurrentReads = n }(maxConcurrentReads)
+	maxConcurrentReads = 1
+	paths, dependencies = walk()
+	assert.ElementsMatch(t, expectedPaths, paths)
+	assert.Equal(t, expectedDependencies, dependencies)
+}
+
+func TestTransformRunsOnceForWalkAndLoad(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a unix shell")
+	}
+	temppath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(temppath)
+	srcPath := testutil.MakeSubdirectoryTree(temppath, "app/src")
+	testutil.WriteTextFile(srcPath, "App.js", `System.register(["./styles.scss"], function (exports_1, context_1) {`)
+	testutil.WriteTextFile(srcPath, "styles.scss", "a {}")
+	runs := filepath.Join(temppath, "runs.txt")
+
+	ws := source.NewWorkspace(temppath)
+	loaders, err := source.NewLoaderRegistry(nil)
+	assert.Nil(t, err)
+	assert.Nil(t, loaders.AddTransforms([]*config.TransformConfig{{Match: ".scss", Command: "echo run >> '" + runs + "'; cat", Output: source.LoaderCSS}}))
+	ws.SetLoaders(loaders)
+
+	fileset := BuildFileSet(ws, "app/src/App", nil, map[string]string{})
+	styles := fileset.Get("app/src/styles.scss")
+	if assert.NotNil(t, styles) {
+		styles.EnsureLoaded(nil)
+		assert.Nil(t, styles.LoadError())
+	}
+	assert.Equal(t, "run\n", testutil.ReadTextFile(temppath, "runs.txt"))
+}
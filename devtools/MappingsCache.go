@@ -0,0 +1,81 @@
+package devtools
+
+import (
+	"strings"
+	"github.com/mrcrowl/swarm/source"
+)
+
+// MappingsCache remembers the chunk of composed mappings generated for each source map in a bundle, so that when the
+// bundle is rebuilt, only the chunks of changed source maps (and of any which follow one that ends differently) are
+// generated again.  It isn't safe for concurrent use
+type MappingsCache struct {
+	chunks map[*source.Mapping]*mappingsChunk
+}
+
+// mappingsChunk is the part of the composed mappings for one source map, along with what it was generated from
+type mappingsChunk struct {
+	spacerLines   int
+	fileLineCount int
+	previousDelta source.Segment // the state left by the chunk before
+	nameDelta     int
+
+	mappings      string
+	endDelta      source.Segment // the state left for the chunk after
+	hasNames      bool
+	lastNameIndex int
+	nameCount     int
+}
+
+// NewMappingsCache creates an empty MappingsCache
+func NewMappingsCache() *MappingsCache {
+	return &MappingsCache{chunks: map[*source.Mapping]*mappingsChunk{}}
+}
+
+// get gets the chunk generated for a source map, if it was generated from the same state
+func (cache *MappingsCache) get(smap *sourceMap, previousDelta source.Segment, nameDelta int) *mappingsChunk {
+	if cache == nil {
+		return nil
+	}
+	chunk := cache.chunks[smap.mapping]
+	if chunk == nil || chunk.spacerLines != smap.spacerLines || chunk.fileLineCount != smap.fileLineCount ||
+		chunk.previousDelta != previousDelta || chunk.nameDelta != nameDelta {
+		return nil
+	}
+	return chunk
+}
+
+// replace replaces the cached chunks with those of the latest build, which forgets any source maps no longer bundled
+func (cache *MappingsCache) replace(chunks map[*source.Mapping]*mappingsChunk) {
+	if cache != nil {
+		cache.chunks = chunks
+	}
+}
+
+// generateChunk generates the part of the composed mappings for a source map, given the state left by the chunk before
+func (smap *sourceMap) generateChunk(previousDelta source.Segment, nameDelta int) *mappingsChunk {
+	smap.mapping.EnsureLoaded()
+	mappings := smap.OffsetMappings(previousDelta)
+	playback := smap.PlayMappings()
+	endDelta := playback.SegmentDelta
+	endDelta.SourceFile = 1
+	nameCount := len(smap.mapping.Names())
+	mappings, hasNames := offsetFirstName(mappings, nameCount, nameDelta)
+
+	var sb strings.Builder
+	sb.WriteString(strings.Repeat(";", smap.spacerLines))
+	sb.WriteString(mappings)
+	additionalSeparators := 1 + (smap.fileLineCount - playback.LineCount)
+	sb.WriteString(strings.Repeat(";", additionalSeparators))
+
+	return &mappingsChunk{
+		spacerLines:   smap.spacerLines,
+		fileLineCount: smap.fileLineCount,
+		previousDelta: previousDelta,
+		nameDelta:     nameDelta,
+		mappings:      sb.String(),
+		endDelta:      endDelta,
+		hasNames:      hasNames,
+		lastNameIndex: playback.NameIndex,
+		nameCount:     nameCount,
+	}
+}
@@ -0,0 +1,72 @@
+package devtools
+
+import (
+	"testing"
+
+	"github.com/mrcrowl/swarm/source"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMappingsCacheMatchesUncachedMappings(t *testing.T) {
+	first := source.NewMappingForTesting(&source.MapConfig{Sources: []string{"First.ts"}, Names: []string{"foo"}, Mappings: ";;AAAAA;CAAC"})
+	second := source.NewMappingForTesting(&source.MapConfig{Sources: []string{"Second.ts"}, Mappings: mapping1})
+	third := source.NewMappingForTesting(&source.MapConfig{Sources: []string{"Third.ts"}, Names: []string{"bar"}, Mappings: "AAAA,EAACA"})
+	fourth := source.NewMappingForTesting(&source.MapConfig{Sources: []string{"Fourth.ts"}, Mappings: mapping2})
+	changedSecond := source.NewMappingForTesting(&source.MapConfig{Sources: []string{"Second.ts"}, Names: []string{"baz"}, Mappings: ";AAAAA;EAAE"})
+
+	build := func(cache *MappingsCache, maps ...*source.Mapping) string {
+		smb := NewSourceMapBuilder("bundle", len(maps))
+		if cache != nil {
+			smb.UseCache(cache)
+		}
+		for i, mapping := range maps {
+			smb.AddSourceMap(i%2, 10, mapping)
+		}
+		return smb.GenerateMappings()
+	}
+
+	cases := map[string][]*source.Mapping{
+		"unchanged":        {first, second, third, fourth},
+		"changed middle":   {first, changedSecond, third, fourth},
+		"removed middle":   {first, third, fourth},
+		"reordered":        {fourth, third, second, first},
+		"added at the end": {first, second, third, fourth, changedSecond},
+	}
+	for name, maps := range cases {
+		cache := NewMappingsCache()
+		build(cache, first, second, third, fourth)
+		assert.Equal(t, build(nil, maps...), build(cache, maps...), name)
+		assert.Len(t, cache.chunks, len(maps), name)
+	}
+}
+
+func TestMappingsCacheReusesUnchangedChunks(t *testing.T) {
+	first := source.NewMappingForTesting(&source.MapConfig{Sources: []string{"First.ts"}, Mappings: mapping1})
+	second := source.NewMappingForTesting(&source.MapConfig{Sources: []string{"Second.ts"}, Mappings: mapping2})
+	third := source.NewMappingForTesting(&source.MapConfig{Sources: []string{"Third.ts"}, Mappings: firstMappingsNoChange})
+	changedSecond := source.NewMappingForTesting(&source.MapConfig{Sources: []string{"Second.ts"}, Mappings: "AAAA"})
+
+	cache := NewMappingsCache()
+	build := func(maps ...*source.Mapping) {
+		smb := NewSourceMapBuilder("bundle", len(maps))
+		smb.UseCache(cache)
+		for _, mapping := range maps {
+			smb.AddSourceMap(0, 20, mapping)
+		}
+		smb.GenerateMappings()
+	}
+
+	build(first, second, third)
+	firstChunk, thirdChunk := cache.chunks[first], cache.chunks[third]
+	build(first, second, third)
+	assert.True(t, firstChunk == cache.chunks[first])
+	assert.True(t, thirdChunk == cache.chunks[third])
+
+	// the third chunk follows one which now ends differently, so its first segment must be offset again
+	build(first, changedSecond, third)
+	assert.True(t, firstChunk == cache.chunks[first])
+	assert.False(t, thirdChunk == cache.chunks[third])
+	_, found := cache.chunks[second]
+	assert.False(t, found)
+}
@@ -1,14 +1,19 @@
 package devtools
 
 import (
+	"encoding/json"
 	"strings"
 	"github.com/mrcrowl/swarm/source"
+	"github.com/mrcrowl/swarm/util"
 )
 
 // SourceMapBuilder is used for compiling source maps from existing source map files
 type SourceMapBuilder struct {
-	filename string
-	sources  []*sourceMap
+	filename     string
+	sources      []*sourceMap
+	lineEdits    []LineEdit
+	embedSources bool
+	cache        *MappingsCache
 }
 
 // NewSourceMapBuilder creates a new sourceMapBuilder
@@ -19,6 +24,23 @@ func NewSourceMapBuilder(filename string, capacity int) *SourceMapBuilder {
 	}
 }
 
+// EditLines records how the generated lines were changed after bundling (e.g. by minification), so the mappings can follow them
+func (smb *SourceMapBuilder) EditLines(edits []LineEdit) {
+	smb.lineEdits = edits
+}
+
+// EmbedSources includes the contents of the original sources in the source map (as sourcesContent), so they needn't
+// be fetched separately
+func (smb *SourceMapBuilder) EmbedSources() {
+	smb.embedSources = true
+}
+
+// UseCache reuses the mappings generated for unchanged source maps by an earlier builder, and remembers those
+// generated by this one
+func (smb *SourceMapBuilder) UseCache(cache *MappingsCache) {
+	smb.cache = cache
+}
+
 // AddSourceMap adds a source map to be included in the build
 func (smb *SourceMapBuilder) AddSourceMap(spacerLines int, fileLineCount int, mapping *source.Mapping) {
 	source := &sourceMap{
@@ -43,27 +65,164 @@ func (smb *SourceMapBuilder) String() string {
 		}
 		sb.WriteString("\"" + source.path() + "\"")
 	}
+	sb.WriteString(`]`)
+	if smb.embedSources {
+		sb.WriteString(`,"sourcesContent":[`)
+		for i, source := range smb.sources {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			if content := sourceContent(source); content != nil {
+				sb.WriteString(util.JSONEncodeString(*content))
+			} else {
+				sb.WriteString("null")
+			}
+		}
+		sb.WriteString(`]`)
+	}
+	sb.WriteString(`,"names":[`)
+	for i, name := range smb.names() {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(util.JSONEncodeString(name))
+	}
 	sb.WriteString(`],"mappings":"`)
-	sb.WriteString(smb.GenerateMappings())
+	mappings := smb.GenerateMappings()
+	if smb.lineEdits != nil {
+		mappings = RemapMappings(mappings, smb.lineEdits)
+	}
+	sb.WriteString(mappings)
 	sb.WriteString(`"}`)
 	return sb.String()
 }
 
-// GenerateMappings outputs a string of the compiled sourcemap
+// names concatenates the names of each source map, in the same order as GenerateMappings
+func (smb *SourceMapBuilder) names() []string {
+	var names []string
+	for _, source := range smb.sources {
+		source.mapping.EnsureLoaded()
+		names = append(names, source.mapping.Names()...)
+	}
+	return names
+}
+
+// GenerateMappings outputs a string of the compiled sourcemap.  With a MappingsCache, the chunk of mappings for each
+// source map is only generated again when the source map, or the state left by the chunk before it, has changed
 func (smb *SourceMapBuilder) GenerateMappings() string {
 	var sb strings.Builder
 	var lastMappingsDelta = source.Segment{GeneratedColumn: 0, SourceFile: 0, SourceLine: 0, SourceColumn: 0}
-	for _, source := range smb.sources {
-		sb.WriteString(strings.Repeat(";", source.spacerLines))
-		source.mapping.EnsureLoaded()
-		mappings := source.OffsetMappings(lastMappingsDelta)
-		playback := source.PlayMappings()
-		lastMappingsDelta = playback.SegmentDelta
-		lastMappingsDelta.SourceFile = 1
-
-		sb.WriteString(mappings)
-		additionalSeparators := 1 + (source.fileLineCount - playback.LineCount)
-		sb.WriteString(strings.Repeat(";", additionalSeparators))
+	nameOffset, lastNameIndex := 0, 0 // indexes into the concatenated names
+	chunks := make(map[*source.Mapping]*mappingsChunk, len(smb.sources))
+	for _, smap := range smb.sources {
+		// the first name index of each map is relative to the last name of the previous map with one
+		nameDelta := nameOffset - lastNameIndex
+		chunk := smb.cache.get(smap, lastMappingsDelta, nameDelta)
+		if chunk == nil {
+			chunk = smap.generateChunk(lastMappingsDelta, nameDelta)
+		}
+		chunks[smap.mapping] = chunk
+
+		sb.WriteString(chunk.mappings)
+		lastMappingsDelta = chunk.endDelta
+		if chunk.hasNames {
+			lastNameIndex = nameOffset + chunk.lastNameIndex
+		}
+		nameOffset += chunk.nameCount
 	}
+	smb.cache.replace(chunks)
 	return sb.String()
 }
+
+// indexMap is the JSON structure of an index source map, which has a section for each file's own source map
+type indexMap struct {
+	Version  int             `json:"version"`
+	File     string          `json:"file"`
+	Sections []*indexSection `json:"sections"`
+}
+
+type indexSection struct {
+	Offset indexOffset       `json:"offset"`
+	Map    *source.MapConfig `json:"map"`
+}
+
+type indexOffset struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// IndexMap outputs an index source map, with a section for each source map, offset to the line its file starts at.
+// Unlike String, this doesn't need to replay the mappings of every source map
+func (smb *SourceMapBuilder) IndexMap() string {
+	index := &indexMap{Version: 3, File: smb.filename + ".js", Sections: []*indexSection{}}
+	line := 0
+	for _, smap := range smb.sources {
+		line += smap.spacerLines
+		startLine := line
+		line += smap.fileLineCount
+
+		smap.mapping.EnsureLoaded()
+		mappings := smap.mapping.Mappings()
+		if smb.lineEdits != nil {
+			var removed bool
+			if startLine, mappings, removed = remapSection(startLine, smap.fileLineCount, mappings, smb.lineEdits); removed {
+				continue
+			}
+		}
+
+		sectionMap := &source.MapConfig{
+			Version:  3,
+			Sources:  []string{smap.path()},
+			Names:    smap.mapping.Names(),
+			Mappings: mappings,
+		}
+		if sectionMap.Names == nil {
+			sectionMap.Names = []string{}
+		}
+		if smb.embedSources {
+			sectionMap.SourcesContent = []*string{sourceContent(smap)}
+		}
+		index.Sections = append(index.Sections, &indexSection{indexOffset{startLine, 0}, sectionMap})
+	}
+
+	indexJSON, _ := json.Marshal(index)
+	return string(indexJSON)
+}
+
+// remapSection applies the edits to the lines of a section, returning the line it now starts at, or true if every
+// line was removed
+func remapSection(startLine int, lineCount int, mappings string, edits []LineEdit) (int, string, bool) {
+	if startLine >= len(edits) {
+		return startLine, mappings, false
+	}
+	endLine := startLine + lineCount
+	if endLine > len(edits) {
+		endLine = len(edits)
+	}
+
+	newStartLine := -1
+	sectionEdits := make([]LineEdit, 0, endLine-startLine)
+	for _, edit := range edits[startLine:endLine] {
+		if edit.Line >= 0 && newStartLine < 0 {
+			newStartLine = edit.Line
+		}
+		sectionEdits = append(sectionEdits, edit)
+	}
+	if newStartLine < 0 {
+		return 0, "", true
+	}
+	for i := range sectionEdits {
+		if sectionEdits[i].Line >= 0 {
+			sectionEdits[i].Line -= newStartLine
+		}
+	}
+	return newStartLine, RemapMappings(mappings, sectionEdits), false
+}
+
+// sourceContent gets the content of a source map's source, or nil if it can't be found
+func sourceContent(smap *sourceMap) *string {
+	if content, ok := smap.mapping.SourceContent(); ok {
+		return &content
+	}
+	return nil
+}
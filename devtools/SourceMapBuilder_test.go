@@ -0,0 +1,156 @@
+package devtools
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mrcrowl/swarm/source"
+	"github.com/mrcrowl/swarm/util"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourceMapBuilderMergesNames(t *testing.T) {
+	content := "let foo = bar;"
+	first := source.NewMappingForTesting(&source.MapConfig{
+		Sources:        []string{"First.ts"},
+		SourcesContent: []*string{&content},
+		Names:          []string{"foo", "bar"},
+		Mappings:       "AAAAA,CAACC",
+	})
+	second := source.NewMappingForTesting(&source.MapConfig{
+		Sources:  []string{"Second.ts"},
+		Mappings: "AAAA",
+	})
+	third := source.NewMappingForTesting(&source.MapConfig{
+		Sources:  []string{"Third.ts"},
+		Names:    []string{"baz"},
+		Mappings: "AAAA,EAACA",
+	})
+
+	smb := NewSourceMapBuilder("bundle", 3)
+	smb.AddSourceMap(0, 1, first)
+	smb.AddSourceMap(0, 1, second)
+	smb.AddSourceMap(0, 1, third)
+	assert.Equal(t, []string{"foo", "bar", "baz"}, smb.names())
+	assert.Equal(t, [][][]int{
+		{{0, 0, 0, 0, 0}, {1, 0, 0, 1, 1}},
+		{{0, 1, 0, 0}},
+		{{0, 2, 0, 0}, {2, 2, 0, 1, 2}},
+		nil,
+	}, util.DecodeMappings(smb.GenerateMappings()))
+	assert.NotContains(t, smb.String(), "sourcesContent")
+
+	smb.EmbedSources()
+	sourceMap, err := source.ParseSourceMapConfig(smb.String())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"foo", "bar", "baz"}, sourceMap.Names)
+	assert.Equal(t, []*string{&content, nil, nil}, sourceMap.SourcesContent)
+}
+
+func TestOffsetFirstName(t *testing.T) {
+	cases := map[string]struct {
+		mappings  string
+		nameCount int
+		delta     int
+		expected  string
+		found     bool
+	}{
+		"first segment": {"AAAAA,CAACC", 2, 2, "AAAAE,CAACC", true},
+		"later line":    {";AAAA;CAACC", 2, -1, ";AAAA;CAACA", true},
+		"no names":      {"AAAA;CAAC", 0, 3, "AAAA;CAAC", false},
+		"no name list":  {"AAAAA", 0, 3, "AAAAA", false},
+		"empty":         {"", 0, 3, "", false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			actual, found := offsetFirstName(tc.mappings, tc.nameCount, tc.delta)
+			assert.Equal(t, tc.expected, actual)
+			assert.Equal(t, tc.found, found)
+		})
+	}
+}
+
+// resolvedSegment is a segment of a source map, with absolute positions and the name of its source
+type resolvedSegment struct {
+	line, column             int
+	source                   string
+	sourceLine, sourceColumn int
+}
+
+func resolveSegments(lineOffset int, sourceMap *source.MapConfig) []resolvedSegment {
+	var segments []resolvedSegment
+	for line, lineSegments := range util.DecodeMappings(sourceMap.Mappings) {
+		for _, segment := range lineSegments {
+			segments = append(segments, resolvedSegment{lineOffset + line, segment[0], sourceMap.Sources[segment[1]], segment[2], segment[3]})
+		}
+	}
+	return segments
+}
+
+func createIndexMapBuilder() *SourceMapBuilder {
+	smb := NewSourceMapBuilder("bundle", 3)
+	smb.AddSourceMap(2, 20, source.NewMappingForTesting(&source.MapConfig{Sources: []string{"First.ts"}, Mappings: mapping1}))
+	smb.AddSourceMap(1, 10, source.NewMappingForTesting(&source.MapConfig{Sources: []string{"Second.ts"}, Mappings: mapping2}))
+	smb.AddSourceMap(0, 19, source.NewMappingForTesting(&source.MapConfig{Sources: []string{"Third.ts"}, Names: []string{}, Mappings: firstMappingsNoChange}))
+	return smb
+}
+
+func TestIndexMapMatchesFlattenedMap(t *testing.T) {
+	removeLines := func(removed ...int) []LineEdit {
+		edits := make([]LineEdit, 60)
+		line := 0
+		for i := range edits {
+			edits[i] = LineEdit{Line: line}
+			for _, r := range removed {
+				if r == i {
+					edits[i].Line = -1
+				}
+			}
+			if edits[i].Line >= 0 {
+				line++
+			}
+		}
+		edits[4].Removed = []ColumnRange{{2, 6}}
+		return edits
+	}
+
+	cases := map[string][]LineEdit{
+		"unedited":              nil,
+		"minified":              removeLines(0, 1, 5, 30),
+		"section removed":       removeLines(22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32),
+		"section start removed": removeLines(33, 34),
+	}
+	for name, edits := range cases {
+		t.Run(name, func(t *testing.T) {
+			smb := createIndexMapBuilder()
+			if edits != nil {
+				smb.EditLines(edits)
+			}
+
+			flattened, err := source.ParseSourceMapConfig(smb.String())
+			assert.Nil(t, err)
+			var index indexMap
+			assert.Nil(t, json.Unmarshal([]byte(smb.IndexMap()), &index))
+			assert.Equal(t, "bundle.js", index.File)
+
+			var fromSections []resolvedSegment
+			for _, section := range index.Sections {
+				fromSections = append(fromSections, resolveSegments(section.Offset.Line, section.Map)...)
+			}
+			assert.Equal(t, resolveSegments(0, flattened), fromSections)
+		})
+	}
+}
+
+func BenchmarkFlattenedSourceMap(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_ = createIndexMapBuilder().String()
+	}
+}
+
+func BenchmarkIndexSourceMap(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_ = createIndexMapBuilder().IndexMap()
+	}
+}
@@ -0,0 +1,172 @@
+package devtools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"github.com/mrcrowl/swarm/source"
+	"github.com/mrcrowl/swarm/util"
+)
+
+// OriginalPosition is the position in an original source that a generated position maps to.  Lines and columns are
+// zero-based, as they are within a source map
+type OriginalPosition struct {
+	Source string
+	Line   int
+	Column int
+	Name   string // empty unless the segment has a name
+}
+
+// LookupPosition finds the original position of a (zero-based) generated line and column, using the last segment of
+// the line which starts at or before the column.  Returns false if that segment (or the line) isn't mapped
+func LookupPosition(sourceMap *source.MapConfig, line int, column int) (*OriginalPosition, bool) {
+	if problem := util.InvalidMappings(sourceMap.Mappings); problem != "" {
+		return nil, false
+	}
+	return lookupSegment(sourceMap, util.DecodeMappings(sourceMap.Mappings), line, column)
+}
+
+// lookupSegment finds the original position of a generated line and column within the decoded mappings of a source map
+func lookupSegment(sourceMap *source.MapConfig, lines [][][]int, line int, column int) (*OriginalPosition, bool) {
+	if line < 0 || line >= len(lines) {
+		return nil, false
+	}
+
+	var found []int
+	for _, segment := range lines[line] {
+		if len(segment) == 0 {
+			continue // an empty segment, or a truncated VLQ
+		}
+		if segment[0] > column {
+			break
+		}
+		found = segment
+	}
+	if len(found) < 4 || found[1] < 0 || found[1] >= len(sourceMap.Sources) {
+		return nil, false
+	}
+
+	position := &OriginalPosition{Source: sourceURL(sourceMap, found[1]), Line: found[2], Column: found[3]}
+	if len(found) >= 5 && found[4] >= 0 && found[4] < len(sourceMap.Names) {
+		position.Name = sourceMap.Names[found[4]]
+	}
+	return position, true
+}
+
+// BundleSourceMap is the source map of a bundle, either flattened or an index map, prepared for looking up many
+// positions.  The mappings of each section are only decoded when a position within it is first looked up
+type BundleSourceMap struct {
+	sections []*bundleSection
+}
+
+type bundleSection struct {
+	line      int
+	column    int
+	sourceMap *source.MapConfig
+	lines     [][][]int // decoded mappings, or nil until needed
+}
+
+// ParseBundleSourceMap parses the JSON of a bundle's source map, as produced by SourceMapBuilder's String or IndexMap
+func ParseBundleSourceMap(sourceMapJSON string) (*BundleSourceMap, error) {
+	var index indexMap
+	if err := json.Unmarshal([]byte(sourceMapJSON), &index); err != nil {
+		return nil, errors.New("Invalid JSON in source map: " + err.Error())
+	}
+	if len(index.Sections) == 0 {
+		sourceMap, err := source.ParseSourceMapConfig(sourceMapJSON)
+		if err != nil {
+			return nil, err
+		}
+		return &BundleSourceMap{[]*bundleSection{{sourceMap: sourceMap}}}, nil
+	}
+
+	bsm := &BundleSourceMap{}
+	for _, section := range index.Sections {
+		if section.Map != nil {
+			bsm.sections = append(bsm.sections, &bundleSection{line: section.Offset.Line, column: section.Offset.Column, sourceMap: section.Map})
+		}
+	}
+	return bsm, nil
+}
+
+// Lookup finds the original position of a (zero-based) generated line and column of the bundle
+func (bsm *BundleSourceMap) Lookup(line int, column int) (*OriginalPosition, bool) {
+	var found *bundleSection
+	for _, section := range bsm.sections {
+		if section.line > line || (section.line == line && section.column > column) {
+			break
+		}
+		found = section
+	}
+	if found == nil {
+		return nil, false
+	}
+
+	if found.lines == nil {
+		if util.InvalidMappings(found.sourceMap.Mappings) != "" {
+			return nil, false
+		}
+		found.lines = util.DecodeMappings(found.sourceMap.Mappings)
+	}
+	if line == found.line {
+		column -= found.column
+	}
+	return lookupSegment(found.sourceMap, found.lines, line-found.line, column)
+}
+
+// VerifySourceMap checks that every segment of a source map refers to one of its sources, and to a line within that
+// source, and that any name refers to one of its names.  lineCounts gives the number of lines in each source (by its
+// url), where known.  Returns a description of each problem found
+func VerifySourceMap(sourceMap *source.MapConfig, lineCounts map[string]int) []string {
+	if problem := util.InvalidMappings(sourceMap.Mappings); problem != "" {
+		return []string{problem}
+	}
+
+	var problems []string
+	for line, segments := range util.DecodeMappings(sourceMap.Mappings) {
+		column := 0
+		for _, segment := range segments {
+			if len(segment) == 0 {
+				// an empty segment, or a truncated VLQ, at the column of the segment before it
+				problems = append(problems, fmt.Sprintf("%d:%d: empty segment", line+1, column))
+				continue
+			}
+			column = segment[0]
+			describe := func(format string, args ...interface{}) {
+				at := fmt.Sprintf("%d:%d: ", line+1, segment[0])
+				problems = append(problems, at+fmt.Sprintf(format, args...))
+			}
+
+			switch {
+			case len(segment) == 1:
+				continue
+			case len(segment) < 4:
+				describe("segment has %d fields, expected 1, 4 or 5", len(segment))
+				continue
+			case segment[1] < 0 || segment[1] >= len(sourceMap.Sources):
+				describe("source index %d is out of range (%d sources)", segment[1], len(sourceMap.Sources))
+				continue
+			}
+
+			url := sourceURL(sourceMap, segment[1])
+			if lineCount, known := lineCounts[url]; known && (segment[2] < 0 || segment[2] >= lineCount) {
+				describe("line %d is out of range for '%s' (%d lines)", segment[2]+1, url, lineCount)
+			} else if segment[2] < 0 || segment[3] < 0 {
+				describe("negative position %d:%d in '%s'", segment[2]+1, segment[3], url)
+			}
+			if len(segment) >= 5 && (segment[4] < 0 || segment[4] >= len(sourceMap.Names)) {
+				describe("name index %d is out of range (%d names)", segment[4], len(sourceMap.Names))
+			}
+		}
+	}
+	return problems
+}
+
+// sourceURL gets the url of one of a source map's sources, including its sourceRoot
+func sourceURL(sourceMap *source.MapConfig, index int) string {
+	if sourceMap.SourceRoot == "" {
+		return sourceMap.Sources[index]
+	}
+	return strings.TrimSuffix(sourceMap.SourceRoot, "/") + "/" + sourceMap.Sources[index]
+}
@@ -0,0 +1,77 @@
+package devtools
+
+import (
+	"testing"
+
+	"github.com/mrcrowl/swarm/source"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupPosition(t *testing.T) {
+	sourceMap := &source.MapConfig{
+		SourceRoot: "src/",
+		Sources:    []string{"a.ts", "b.ts"},
+		Names:      []string{"foo"},
+		Mappings:   ";IAAA,KAAKA;CCAL,CAAC;E",
+	}
+	cases := map[string]struct {
+		line     int
+		column   int
+		expected *OriginalPosition
+	}{
+		"start of segment": {1, 4, &OriginalPosition{Source: "src/a.ts", Line: 0, Column: 0}},
+		"within segment":   {1, 7, &OriginalPosition{Source: "src/a.ts", Line: 0, Column: 0}},
+		"named segment":    {1, 9, &OriginalPosition{Source: "src/a.ts", Line: 0, Column: 5, Name: "foo"}},
+		"next source":      {2, 1, &OriginalPosition{Source: "src/b.ts", Line: 0, Column: 0}},
+		"before first":     {1, 2, nil},
+		"unmapped line":    {0, 0, nil},
+		"unmapped segment": {3, 4, nil},
+		"beyond last line": {9, 0, nil},
+	}
+	for name, tc := range cases {
+		position, found := LookupPosition(sourceMap, tc.line, tc.column)
+		assert.Equal(t, tc.expected != nil, found, name)
+		assert.Equal(t, tc.expected, position, name)
+	}
+}
+
+func TestLookupPositionEmptySegments(t *testing.T) {
+	cases := map[string]struct {
+		mappings string
+		line     int
+		column   int
+		expected *OriginalPosition
+	}{
+		"empty segments": {",,", 0, 0, nil},
+		"truncated VLQ":  {"AAAA;g", 1, 0, nil},
+		"after empty":    {",IAAA,,", 0, 5, &OriginalPosition{Source: "a.ts", Line: 0, Column: 0}},
+	}
+	for name, tc := range cases {
+		sourceMap := &source.MapConfig{Sources: []string{"a.ts"}, Mappings: tc.mappings}
+		position, found := LookupPosition(sourceMap, tc.line, tc.column)
+		assert.Equal(t, tc.expected != nil, found, name)
+		assert.Equal(t, tc.expected, position, name)
+	}
+}
+
+func TestVerifySourceMap(t *testing.T) {
+	cases := map[string]struct {
+		mappings string
+		expected []string
+	}{
+		"valid":            {"AAAA;AACA,IAAIA;C", nil},
+		"source index":     {"AAAA;AEAA", []string{"2:0: source index 2 is out of range (2 sources)"}},
+		"line beyond file": {"AAAA;AAEA", []string{"2:0: line 3 is out of range for 'a.ts' (2 lines)"}},
+		"unknown lines":    {"ACAA;AAEA", nil},
+		"name index":       {"AAAAC", []string{"1:0: name index 1 is out of range (1 names)"}},
+		"field count":      {"AA", []string{"1:0: segment has 2 fields, expected 1, 4 or 5"}},
+		"invalid mappings": {"AA!A", []string{`invalid character '!' in mappings at offset 2`}},
+		"empty segments":   {",,", []string{"1:0: empty segment", "1:0: empty segment", "1:0: empty segment"}},
+		"truncated VLQ":    {"AAAA;IAAA,g", []string{"2:4: empty segment"}},
+	}
+	for name, tc := range cases {
+		sourceMap := &source.MapConfig{Sources: []string{"a.ts", "b.ts"}, Names: []string{"foo"}, Mappings: tc.mappings}
+		assert.Equal(t, tc.expected, VerifySourceMap(sourceMap, map[string]int{"a.ts": 2}), name)
+	}
+}
@@ -0,0 +1,72 @@
+package devtools
+
+import (
+	"github.com/mrcrowl/swarm/util"
+)
+
+// LineEdit describes what happened to a generated line after the source map was built, e.g. during minification
+type LineEdit struct {
+	Line    int           // the line's new index, or -1 if it was removed
+	Removed []ColumnRange // ranges of columns removed from the line, in ascending order
+}
+
+// ColumnRange is a half-open range of columns [Start, End)
+type ColumnRange struct {
+	Start int
+	End   int
+}
+
+// Column maps a column from before the edit to after it.  Columns within a removed
+// range are moved to the start of whatever follows the range
+func (edit *LineEdit) Column(column int) int {
+	removed := 0
+	for _, r := range edit.Removed {
+		if column < r.Start {
+			break
+		}
+		if column < r.End {
+			return r.Start - removed
+		}
+		removed += r.End - r.Start
+	}
+	return column - removed
+}
+
+// RemapMappings rewrites a mappings string so that it describes the generated lines after they have been edited.
+// Lines without an edit keep their position relative to the last edited line
+func RemapMappings(mappings string, edits []LineEdit) string {
+	if mappings == "" {
+		return mappings
+	}
+
+	var remapped [][][]int
+	lastLine := -1
+	for i, segments := range util.DecodeMappings(mappings) {
+		edit := LineEdit{Line: lastLine + 1}
+		if i < len(edits) {
+			edit = edits[i]
+		}
+		if edit.Line < 0 {
+			continue
+		}
+		lastLine = edit.Line
+		for len(remapped) <= edit.Line {
+			remapped = append(remapped, nil)
+		}
+
+		for _, absolute := range segments {
+			if len(absolute) == 0 {
+				continue
+			}
+			absolute[0] = edit.Column(absolute[0])
+			remappedSegments := remapped[edit.Line]
+			if n := len(remappedSegments); n > 0 && remappedSegments[n-1][0] == absolute[0] {
+				remappedSegments[n-1] = absolute // the later segment describes the code that now starts at this column
+			} else {
+				remappedSegments = append(remappedSegments, absolute)
+			}
+			remapped[edit.Line] = remappedSegments
+		}
+	}
+	return util.EncodeMappings(remapped)
+}
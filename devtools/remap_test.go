@@ -0,0 +1,44 @@
+package devtools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineEditColumn(t *testing.T) {
+	edit := &LineEdit{Line: 0, Removed: []ColumnRange{{Start: 0, End: 4}, {Start: 8, End: 10}}}
+	cases := map[int]int{0: 0, 2: 0, 4: 0, 7: 3, 8: 4, 9: 4, 10: 4, 12: 6}
+	for column, expected := range cases {
+		assert.Equal(t, expected, edit.Column(column), "column %d", column)
+	}
+}
+
+func TestRemapMappings(t *testing.T) {
+	cases := map[string]struct {
+		mappings string
+		edits    []LineEdit
+		expected string
+	}{
+		"unchanged":    {mappings: "AAAA;AACA", edits: []LineEdit{{Line: 0}, {Line: 1}}, expected: "AAAA;AACA"},
+		"no edits":     {mappings: "AAAA;AACA", edits: nil, expected: "AAAA;AACA"},
+		"removed line": {mappings: "AAAA;AACA;AACA", edits: []LineEdit{{Line: 0}, {Line: -1}, {Line: 1}}, expected: "AAAA;AAEA"},
+		"shifted column": {
+			mappings: "IAAA,KAAK",
+			edits:    []LineEdit{{Line: 0, Removed: []ColumnRange{{Start: 0, End: 4}, {Start: 6, End: 9}}}},
+			expected: "AAAA,EAAK",
+		},
+		"merged columns": {
+			mappings: "AAAA,CAAC,CAAC",
+			edits:    []LineEdit{{Line: 0, Removed: []ColumnRange{{Start: 0, End: 2}}}},
+			expected: "AAAE",
+		},
+		"names kept":       {mappings: "IAAAA;IACAC", edits: []LineEdit{{Line: 0, Removed: []ColumnRange{{Start: 0, End: 4}}}, {Line: 1}}, expected: "AAAAA;IACAC"},
+		"unedited trailer": {mappings: "AAAA;;AACA", edits: []LineEdit{{Line: -1}}, expected: ";AACA"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, RemapMappings(tc.mappings, tc.edits))
+		})
+	}
+}
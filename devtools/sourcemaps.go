@@ -1,9 +1,9 @@
 package devtools
 
 import (
-	"fmt"
 	"strings"
 	"github.com/mrcrowl/swarm/source"
+	"github.com/mrcrowl/swarm/util"
 )
 
 // PlayMappings loops through the mappings to calculate a "delta" that occurs
@@ -12,6 +12,7 @@ func (smap *sourceMap) PlayMappings() *source.MapPlayback {
 	playback := smap.mapping.Playback()
 	if playback == nil {
 		var segDelta source.Segment
+		nameIndex := 0
 		lines := parseMappings(smap.mapping.Mappings())
 		for _, line := range lines {
 			if line != nil {
@@ -21,10 +22,13 @@ func (smap *sourceMap) PlayMappings() *source.MapPlayback {
 					// fmt.Printf("[%d,%d](#%d)=>[%d,%d] |", segDelta.sourceLine, segDelta.sourceColumn,
 					// segDelta.sourceFile, generatedLine, segDelta.generatedColumn)
 				}
+				for _, nameDelta := range line.nameDeltas {
+					nameIndex += nameDelta
+				}
 			}
 			// fmt.Println()
 		}
-		playback = &source.MapPlayback{LineCount: len(lines), SegmentDelta: segDelta}
+		playback = &source.MapPlayback{LineCount: len(lines), SegmentDelta: segDelta, NameIndex: nameIndex}
 		smap.mapping.CachePlayback(playback)
 	}
 	return playback
@@ -43,6 +47,27 @@ func (smap *sourceMap) OffsetMappings(segDelta source.Segment) string {
 	return offsetMappings
 }
 
+// offsetFirstName adds delta to the name index of the first segment which has one, which is the only name index that
+// isn't relative to an earlier one within the same map.  Returns false if no segment has a name, which can only be so
+// when the map has names (nameCount)
+func offsetFirstName(mappings string, nameCount int, delta int) (string, bool) {
+	if nameCount == 0 {
+		return mappings, false
+	}
+	for start := nextNonSeparator(mappings, 0); start >= 0; {
+		end := nextSeparatorOrEOF(mappings, start+1)
+		if values := util.DecodeVLQ(mappings[start:end]); len(values) >= 5 {
+			values[4] += delta
+			return mappings[:start] + util.EncodeVLQ(values) + mappings[end:], true
+		}
+		if end >= len(mappings) {
+			break
+		}
+		start = nextNonSeparator(mappings, end+1)
+	}
+	return mappings, false
+}
+
 type sourceMap struct {
 	spacerLines   int
 	fileLineCount int
@@ -58,7 +83,8 @@ func (smap *sourceMap) path() string {
 }
 
 type line struct {
-	segments []*source.Segment
+	segments   []*source.Segment
+	nameDeltas []int // the (relative) name index of each segment which has one
 }
 
 /*
@@ -106,19 +132,25 @@ func findFirstVLQ(maps string) (start int, end int) {
 
 type vlqReplaceFn func(source.Segment) source.Segment
 
+// replaceFirstVLQ replaces the first segment which has a source (at least 4 fields), skipping any which only have a
+// generated column
 func replaceFirstVLQ(mappings string, replaceFn vlqReplaceFn) string {
-	start, end := findFirstVLQ(mappings)
-	if start < 0 || end < 0 {
-		return mappings
+	for start := nextNonSeparator(mappings, 0); start >= 0; {
+		end := nextSeparatorOrEOF(mappings, start+1)
+		values := util.DecodeVLQ(mappings[start:end])
+		if seg, ok := segmentFromValues(values); ok {
+			replacementVlq := encodeSegment(replaceFn(seg))
+			if extraValues := values[4:]; len(extraValues) > 0 {
+				replacementVlq += util.EncodeVLQ(extraValues) // e.g. the index of a name, which is unchanged
+			}
+			return mappings[:start] + replacementVlq + mappings[end:]
+		}
+		if end >= len(mappings) {
+			break
+		}
+		start = nextNonSeparator(mappings, end+1)
 	}
-
-	before := mappings[:start]
-	after := mappings[end:]
-	vlq := mappings[start:end]
-	values := decodeSegment(vlq)
-	replacementValues := replaceFn(values)
-	replacementVlq := encodeSegment(replacementValues)
-	return before + replacementVlq + after
+	return mappings
 }
 
 func parseMappings(mappings string) []*line {
@@ -135,131 +167,38 @@ func parseLineString(lineString string) *line {
 		return nil
 	}
 	segmentStrings := strings.Split(lineString, ",")
-	segments := make([]*source.Segment, len(segmentStrings))
-	for i, segmentString := range segmentStrings {
-		seg := decodeSegment(segmentString)
-		segments[i] = &seg
-	}
-	return &line{segments}
-}
-
-const base64Map = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/="
-
-func byteToInt(b byte) int {
-	switch {
-	case b >= 'A' && b <= 'Z':
-		return int(b - 'A')
-	case b >= 'a' && b <= 'z':
-		return int(b - 'a' + 26)
-	case b >= '0' && b <= '9':
-		return int(b - '0' + 52)
-	case b == '+':
-		return 62
-	case b == '/':
-		return 63
-	case b == '=':
-		return 64
-	default:
-		panic(fmt.Sprintf("byteToInt received byte out of range: %c", b))
-	}
-}
-
-func intToByte(i int) byte {
-	if i >= 0 && i <= 64 {
-		return base64Map[i]
-	}
-
-	panic(fmt.Sprintf("intToByte received int out of range: %d", i))
-}
-
-// decode decodes a base-64 VLQ string to a strongly-typed segment
-func decodeSegment(s string) source.Segment {
-	values := decode(s)
-	if len(values) >= 4 {
-		return source.Segment{
-			GeneratedColumn: values[0],
-			SourceFile:      values[1],
-			SourceLine:      values[2],
-			SourceColumn:    values[3],
+	l := &line{segments: make([]*source.Segment, 0, len(segmentStrings))}
+	for _, segmentString := range segmentStrings {
+		values := util.DecodeVLQ(segmentString)
+		seg, ok := segmentFromValues(values)
+		if !ok {
+			continue // an empty segment, or one with only a generated column, which doesn't affect the other fields
+		}
+		l.segments = append(l.segments, &seg)
+		if len(values) >= 5 {
+			l.nameDeltas = append(l.nameDeltas, values[4])
 		}
 	}
-	panic(fmt.Sprintf("Encountered decode result with fewer than 4 values: %#v", values))
+	return l
 }
 
-// decode decodes a base-64 VLQ string to a list of integers
-func decode(s string) []int {
-	result := make([]int, 0, 4)
-	shift := uint(0)
-	value := 0
-
-	for _, b := range s {
-		integer := byteToInt(byte(b))
-
-		hasContinuationBit := (integer & 32) > 0
-
-		integer &= 31
-		value += integer << shift
-
-		if hasContinuationBit {
-			shift += 5
-		} else {
-			shouldNegate := (value & 1) > 0
-			value >>= 1
-
-			if shouldNegate {
-				result = append(result, -value)
-			} else {
-				result = append(result, value)
-			}
-
-			// reset
-			value = 0
-			shift = 0
-		}
+// segmentFromValues converts the decoded values of a segment to a strongly-typed segment.  Returns false if the segment
+// has fewer than 4 fields, so has no source
+func segmentFromValues(values []int) (source.Segment, bool) {
+	if len(values) < 4 {
+		return source.Segment{}, false
 	}
-
-	return result
+	return source.Segment{
+		GeneratedColumn: values[0],
+		SourceFile:      values[1],
+		SourceLine:      values[2],
+		SourceColumn:    values[3],
+	}, true
 }
 
-// encode encodes a list of numbers to a VLQ string
-
+// encodeSegment encodes a segment as a base-64 VLQ string
 func encodeSegment(seg source.Segment) string {
 	values := []int{seg.GeneratedColumn, seg.SourceFile, seg.SourceLine, seg.SourceColumn}
-	return encode(values)
-}
-
-// encode encodes a list of numbers to a VLQ string
-func encode(values []int) string {
-	result := make([]byte, 0, 8)
-	for _, n := range values {
-		result = append(result, encodeInteger(n)...)
-	}
-	return string(result)
+	return util.EncodeVLQ(values)
 }
 
-func encodeInteger(n int) []byte {
-	result := make([]byte, 0, 8)
-
-	if n < 0 {
-		n = (-n << 1) | 1
-	} else {
-		n <<= 1
-	}
-
-	for {
-		clamped := n & 31
-		n >>= 5
-
-		if n > 0 {
-			clamped |= 32
-		}
-
-		result = append(result, intToByte(clamped))
-
-		if n <= 0 {
-			break
-		}
-	}
-
-	return result
-}
@@ -186,6 +186,14 @@ func TestReplaceFirstVLQ(t *testing.T) {
 			},
 			expected: "CDCD",
 		},
+		"skips segments without a source": {
+			mappings: "A,,AAAAC;AACA",
+			replacementFn: func(seg source.Segment) source.Segment {
+				seg.SourceFile++
+				return seg
+			},
+			expected: "A,,ACAAC;AACA",
+		},
 	}
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
@@ -239,70 +247,8 @@ func TestParseMapsString(t *testing.T) {
 	}
 }
 
-func TestDecode(t *testing.T) {
-	cases := map[string]struct {
-		vlq      string
-		expected []int
-	}{
-		"AAAC": {
-			vlq:      "AAAC",
-			expected: []int{0, 0, 0, 1},
-		},
-		"ADAA": {
-			vlq:      "ADAA",
-			expected: []int{0, -1, 0, 0},
-		},
-		"AAgBC": {
-			vlq:      "AAgBC",
-			expected: []int{0, 0, 16, 1},
-		},
-		"KAAK": {
-			vlq:      "KAAK",
-			expected: []int{5, 0, 0, 5},
-		},
-		"G9s6a8zns//+": {
-			vlq:      "G9s6aAs8BzC",
-			expected: []int{3, -439502, 0, 966, -41},
-		},
-	}
-	for name, tc := range cases {
-		t.Run(name, func(t *testing.T) {
-			actual := decode(tc.vlq)
-			assert.Equal(t, tc.expected, actual)
-		})
-	}
-}
-
-func TestEncode(t *testing.T) {
-	cases := map[string]struct {
-		expected string
-		nums     []int
-	}{
-		"AAAC": {
-			nums:     []int{0, 0, 0, 1},
-			expected: "AAAC",
-		},
-		"ADAA": {
-			nums:     []int{0, -1, 0, 0},
-			expected: "ADAA",
-		},
-		"AAgBC": {
-			nums:     []int{0, 0, 16, 1},
-			expected: "AAgBC",
-		},
-		"KAAK": {
-			nums:     []int{5, 0, 0, 5},
-			expected: "KAAK",
-		},
-		"G9s6a8zns//+": {
-			nums:     []int{3, -439502, 0, 966, -41},
-			expected: "G9s6aAs8BzC",
-		},
-	}
-	for name, tc := range cases {
-		t.Run(name, func(t *testing.T) {
-			actual := encode(tc.nums)
-			assert.Equal(t, tc.expected, actual)
-		})
-	}
+func TestParseLineStringSkipsSegmentsWithoutSource(t *testing.T) {
+	actual := parseLineString("A,,KAAKC,g")
+	assert.Equal(t, []*source.Segment{{GeneratedColumn: 5, SourceFile: 0, SourceLine: 0, SourceColumn: 5}}, actual.segments)
+	assert.Equal(t, []int{1}, actual.nameDeltas)
 }
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/mrcrowl/swarm/bundle"
+	"github.com/mrcrowl/swarm/util"
+)
+
+const graphCommand = "graph"
+
+// runGraph exports the file-level or module-level dependency graph of a build
+func runGraph(args []string) {
+	_, _, _, moduleSet := loadModuleSet(args)
+
+	filter := &bundle.GraphFilter{
+		Module:          *moduleFlag,
+		RootPath:        *rootFlag,
+		CrossModuleOnly: *crossFlag,
+	}
+	output, err := moduleSet.Graph(*levelFlag, filter).Format(*formatFlag)
+	util.ExitIfError(err, "Failed to export graph: %s", err)
+
+	if *outFlag == "" {
+		fmt.Print(output)
+		return
+	}
+
+	err = ioutil.WriteFile(*outFlag, []byte(output), 0644)
+	util.ExitIfError(err, "Failed to write graph to '%s': %s", *outFlag, err)
+	fmt.Printf("Graph written to '%s'\n", *outFlag)
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/mrcrowl/swarm/bundle"
+	"github.com/mrcrowl/swarm/cache"
 	"github.com/mrcrowl/swarm/config"
 	"github.com/mrcrowl/swarm/monitor"
 	"github.com/mrcrowl/swarm/source"
@@ -20,38 +21,114 @@ const localver = "1.0.11"
 
 var portFlag = flag.Uint16P("port", "p", uint16(8096), "Web server port number")
 var helpFlag = flag.BoolP("help", "h", false, "Shows the usage")
+var outFlag = flag.StringP("out", "o", "", "Output path for the build (default \"dist\") and graph commands")
+var formatFlag = flag.String("format", source.GraphFormatDOT, "Graph output format: dot, json or mermaid")
+var levelFlag = flag.String("level", bundle.GraphLevelFiles, "Graph level of detail: files or modules")
+var moduleFlag = flag.String("module", "", "Restrict the graph to a single module")
+var rootFlag = flag.String("root", "", "Restrict the graph to the files imported by a root-relative path")
+var crossFlag = flag.Bool("cross", false, "Restrict the graph to edges between modules")
+var hashFlag = flag.Bool("hash", false, "Use content-hashed bundle filenames and write a manifest.json (build command)")
+var minifyFlag = flag.Bool("minify", false, "Strip comments and whitespace from the bundles (build command)")
+var atFlag = flag.String("at", "", "Generated line:col of a bundle to look up in its source map (sourcemap command)")
+var verifyFlag = flag.Bool("verify", false, "Check that source maps only point to valid sources and lines (sourcemap command)")
+var noCacheFlag = flag.Bool("no-cache", false, "Disables the persistent build cache")
 
 func main() {
 	ui.PrintTitle(localver)
 	ui.CheckHelp(helpFlag)
 
-	if didUpdate, _ := version.AutoUpdate(localver); didUpdate {
-		fmt.Println("updated. Please restart!")
-		os.Exit(0)
+	args := flag.Args()
+	if len(args) > 0 {
+		switch args[0] {
+		case buildCommand:
+			runBuild(args[1:])
+			return
+		case graphCommand:
+			runGraph(args[1:])
+			return
+		case whyCommand:
+			runWhy(args[1:])
+			return
+		case cyclesCommand:
+			runCycles(args[1:])
+			return
+		case sourcemapCommand:
+			runSourceMap(args[1:])
+			return
+		}
 	}
 
-	// configuration
+	runServer(args)
+}
+
+// loadModuleSet loads the swarm.json configuration, chooses a build (prompting if necessary) and prepares its modules
+func loadModuleSet(buildArgs []string) (*config.SwarmConfig, *config.RuntimeConfig, *source.Workspace, *bundle.ModuleSet) {
+	swarmConfig := loadSwarmConfig()
+	runtimeConfig := ui.ChooseBuild(swarmConfig.Builds, buildArgs)
+	ws, moduleSet := createModuleSet(swarmConfig, runtimeConfig)
+	return swarmConfig, runtimeConfig, ws, moduleSet
+}
+
+// loadSwarmConfig loads the swarm.json configuration from the current directory
+func loadSwarmConfig() *config.SwarmConfig {
 	swarmConfig, err := config.TryLoadSwarmConfigFromCWD(portFlag)
 	util.ExitIfError(err, "Failed to load swarm.json file: %s", err)
-	runtimeConfig := ui.ChooseBuild(swarmConfig.Builds, flag.Args())
+	return swarmConfig
+}
+
+// createModuleSet prepares the workspace and modules of a build
+func createModuleSet(swarmConfig *config.SwarmConfig, runtimeConfig *config.RuntimeConfig) (*source.Workspace, *bundle.ModuleSet) {
 	moduleDescrs, err := config.LoadBuildDescriptionFile(runtimeConfig.BuildPath)
 	util.ExitIfError(err, "Failed to load build description file: '%s'", runtimeConfig.BuildPath)
 
-	// workspace
 	ws := source.NewWorkspace(swarmConfig.RootPath)
+	loaders, err := source.NewLoaderRegistry(swarmConfig.Loaders)
+	util.ExitIfError(err, "Invalid loaders in swarm.json file: %s", err)
+	err = loaders.AddTransforms(swarmConfig.Transforms)
+	util.ExitIfError(err, "Invalid transforms in swarm.json file: %s", err)
+	ws.SetLoaders(loaders)
+	rewriter, err := source.NewSourceRewriter(swarmConfig.SourceRewrites)
+	util.ExitIfError(err, "Invalid sourceRewrites in swarm.json file: %s", err)
+	ws.SetSourceRewriter(rewriter)
+	if !*noCacheFlag {
+		ws.SetCache(cache.Open(swarmConfig.Cache, localver))
+	}
 	normalisedModules := moduleDescrs.NormaliseModules(ws.RootPath())
 	moduleSet := bundle.CreateModuleSet(ws, normalisedModules, runtimeConfig)
+	return ws, moduleSet
+}
+
+// saveCache persists the workspace's build cache, if it has one
+func saveCache(ws *source.Workspace) {
+	if err := ws.Cache().Save(); err != nil {
+		fmt.Printf("WARNING: failed to save build cache: %s\n", err)
+	}
+}
+
+// runServer starts the web server and file monitor, then waits for Ctrl+C
+func runServer(args []string) {
+	if didUpdate, _ := version.AutoUpdate(localver); didUpdate {
+		fmt.Println("updated. Please restart!")
+		os.Exit(0)
+	}
+
+	// configuration & workspace
+	swarmConfig, runtimeConfig, ws, moduleSet := loadModuleSet(args)
 
 	// web server
 	handlers := moduleSet.GenerateHTTPHandlers()
+	handlers[web.GraphPath] = moduleSet.GraphHTTPHandler()
+	handlers[web.SymbolicatePath] = moduleSet.SymbolicateHTTPHandler()
 	serverOptions := web.CreateServerOptions(swarmConfig.RootPath, swarmConfig.Server, handlers, runtimeConfig.BaseHref)
+	serverOptions.Fallback = moduleSet.HashedHTTPHandler()
 	server := web.CreateServer(serverOptions)
 	hotReloader := web.NewHotReloader(server, ws, moduleSet)
 
 	// monitor
-	mon := monitor.NewMonitor(ws, swarmConfig.Monitor)
+	mon := monitor.NewMonitor(ws, swarmConfig.Monitor.WithExtensions(ws.Loaders().Extensions()))
 	mon.RegisterCallback(moduleSet.NotifyChanges)
 	mon.RegisterCallback(hotReloader.NotifyReload)
+	mon.RegisterCallback(func(changes *monitor.EventChangeset) { saveCache(ws) })
 	fmt.Print("Performing initial build...")
 	mon.TriggerManually()
 
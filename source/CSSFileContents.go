@@ -2,6 +2,8 @@ package source
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
 	"path"
 	"path/filepath"
 	"regexp"
@@ -18,6 +20,7 @@ const (
 type CSSFileContents struct {
 	lines         []string
 	rawCSSContent string
+	injectedCSS   string
 }
 
 // BundleLines returns a list of lines ready to include in a SystemJSBundle
@@ -25,11 +28,16 @@ func (cssfc *CSSFileContents) BundleLines() []string {
 	return cssfc.lines
 }
 
-// RawCSSContent returns the CSS as it was originally found in the source file
+// RawCSSContent returns the CSS as it was originally found in the source file, with any @import statements inlined
 func (cssfc *CSSFileContents) RawCSSContent() string {
 	return cssfc.rawCSSContent
 }
 
+// InjectedCSS returns the CSS as it is injected into the page, with its url()s rebased
+func (cssfc *CSSFileContents) InjectedCSS() string {
+	return cssfc.injectedCSS
+}
+
 // SourceMappingURL returns ""
 func (cssfc *CSSFileContents) SourceMappingURL() string {
 	return ""
@@ -49,22 +57,30 @@ const cssTemplate = `System.register("%s", [], function (_export, _context) {
 	}
 });`
 
-// ParseCSSFileContents parses the lines of a CSS file into bundle-ready code
+// ParseCSSFileContents parses the lines of a CSS file into bundle-ready code.  Relative url()s are rebased, so that
+// they resolve from base, i.e. the <base href> of the page the CSS is injected into
 func ParseCSSFileContents(name string, cssContents string, base string) (*CSSFileContents, error) {
-	cssContentsWithURLsRewritten := rewriteURLStatementsInCSS(cssContents, name)
+	cssContentsWithURLsRewritten := rewriteURLStatementsInCSS(cssContents, name, base)
 	encodedFile := util.JSONEncodeString(cssContentsWithURLsRewritten)
 	body := fmt.Sprintf(cssTemplate, name, encodedFile, CSSPrefix+name)
 	lines := util.StringToLines(body)
-	return &CSSFileContents{lines, cssContents}, nil
+	return &CSSFileContents{lines, cssContents, cssContentsWithURLsRewritten}, nil
 }
 
-var rewriteURLPattern = regexp.MustCompile(`url\(['"][^'"]+['"]\)`)
+// rewriteURLPattern matches url() statements, whether their url is single-quoted, double-quoted or unquoted
+var rewriteURLPattern = regexp.MustCompile(`url\(\s*(?:'[^']*'|"[^"]*"|[^'"\s)]*)\s*\)`)
+
+var uriSchemePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
 
-func rewriteURLStatementsInCSS(css string, name string) string {
+// rewriteURLStatementsInCSS rewrites the relative url()s in the css of a file, so they are relative to base instead
+func rewriteURLStatementsInCSS(css string, name string, base string) string {
 	rewrittenCSS := rewriteURLPattern.ReplaceAllStringFunc(css, func(cssURLStatement string) string {
 		uri := extractURI(cssURLStatement)
-		quote := string(cssURLStatement[4])
-		rewrittenURI := rewriteURI(uri, name, "app")
+		if !isRelativeURI(uri) {
+			return cssURLStatement
+		}
+		quote := extractQuote(cssURLStatement)
+		rewrittenURI := rewriteURI(uri, name, base)
 		return "url(" + quote + rewrittenURI + quote + ")"
 	})
 
@@ -72,11 +88,11 @@ func rewriteURLStatementsInCSS(css string, name string) string {
 }
 
 func rewriteURI(uri string, name string, base string) string {
-	if isDataURI(uri) {
+	if !isRelativeURI(uri) {
 		return uri
 	}
 
-	rel, err := filepath.Rel(base, name)
+	rel, err := filepath.Rel(strings.Trim(base, "/"), name)
 	if err != nil {
 		return uri
 	}
@@ -85,11 +101,59 @@ func rewriteURI(uri string, name string, base string) string {
 	return rewrittenURI
 }
 
-// extractURI strips url(' and ') from a css url() statement
+// inlineCSSAssets replaces the relative url()s in the css of a file with data URIs, when the file they refer to is no
+// larger than maxBytes.  Nothing is inlined when maxBytes is 0
+func inlineCSSAssets(css string, absoluteFilepath string, maxBytes int) string {
+	if maxBytes <= 0 {
+		return css
+	}
+
+	return rewriteURLPattern.ReplaceAllStringFunc(css, func(cssURLStatement string) string {
+		uri := extractURI(cssURLStatement)
+		if !isRelativeURI(uri) || strings.Contains(uri, "#") {
+			return cssURLStatement // e.g. an svg sprite, which refers to a fragment of the file
+		}
+		if queryStart := strings.Index(uri, "?"); queryStart >= 0 {
+			uri = uri[:queryStart]
+		}
+
+		assetFilepath := filepath.Join(filepath.Dir(absoluteFilepath), filepath.FromSlash(uri))
+		info, err := os.Stat(assetFilepath)
+		if err != nil || info.IsDir() || info.Size() > int64(maxBytes) {
+			return cssURLStatement
+		}
+		data, err := ioutil.ReadFile(assetFilepath)
+		if err != nil {
+			return cssURLStatement
+		}
+		return `url("` + dataURI(uri, data) + `")`
+	})
+}
+
+// extractURI strips url(' and ') from a css url() statement, along with any whitespace
 func extractURI(cssURLStatement string) string {
-	return cssURLStatement[5 : len(cssURLStatement)-2]
+	uri := strings.TrimSpace(cssURLStatement[4 : len(cssURLStatement)-1])
+	if quote := extractQuote(cssURLStatement); quote != "" {
+		return uri[1 : len(uri)-1]
+	}
+	return uri
+}
+
+// extractQuote gets the quote around the url of a css url() statement, or "" when it is unquoted
+func extractQuote(cssURLStatement string) string {
+	uri := strings.TrimSpace(cssURLStatement[4 : len(cssURLStatement)-1])
+	if uri != "" && (uri[0] == '\'' || uri[0] == '"') {
+		return uri[:1]
+	}
+	return ""
 }
 
 func isDataURI(path string) bool {
 	return strings.HasPrefix(path, "data:")
 }
+
+// isRelativeURI gets whether a url is relative to the file it appears in, rather than rooted (e.g. /img/a.png),
+// protocol-relative (e.g. //cdn.example.com/a.png), absolute (e.g. https://.. or data:..) or a #fragment
+func isRelativeURI(uri string) bool {
+	return uri != "" && !strings.HasPrefix(uri, "/") && !strings.HasPrefix(uri, "#") && !uriSchemePattern.MatchString(uri)
+}
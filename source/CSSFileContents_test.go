@@ -1,8 +1,12 @@
 package source
 
 import (
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/mrcrowl/swarm/testutil"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -31,20 +35,56 @@ func TestRewriteCSSUrls(t *testing.T) {
 	cases := map[string]struct {
 		css          string
 		rewrittenCSS string
+		name         string
 		base         string
-	}{}
+	}{
+		"single quoted":     {inputCSS1, outputCSS1, "app/common/directives/my-directive.css", "app/src"},
+		"double quoted":     {`a { b: url("./bg.png"); }`, `a { b: url("src/bg.png"); }`, "app/src/a.css", "app"},
+		"unquoted":          {`a { b: url(./bg.png); }`, `a { b: url(src/bg.png); }`, "app/src/a.css", "app"},
+		"whitespace":        {`a { b: url( 'bg.png' ); }`, `a { b: url('src/bg.png'); }`, "app/src/a.css", "app"},
+		"base with slashes": {`a { b: url(bg.png); }`, `a { b: url(src/bg.png); }`, "app/src/a.css", "/app/"},
+		"empty base":        {`a { b: url(bg.png); }`, `a { b: url(app/src/bg.png); }`, "app/src/a.css", ""},
+		"rooted":            {`a { b: url(/img/bg.png); }`, `a { b: url(/img/bg.png); }`, "app/src/a.css", "app"},
+		"http":              {`a { b: url("http://example.com/bg.png"); }`, `a { b: url("http://example.com/bg.png"); }`, "app/src/a.css", "app"},
+		"https":             {`a { b: url(https://example.com/bg.png); }`, `a { b: url(https://example.com/bg.png); }`, "app/src/a.css", "app"},
+		"protocol-relative": {`a { b: url('//example.com/bg.png'); }`, `a { b: url('//example.com/bg.png'); }`, "app/src/a.css", "app"},
+		"fragment":          {`a { filter: url(#blur); }`, `a { filter: url(#blur); }`, "app/src/a.css", "app"},
+		"data":              {inputCSS2, outputCSS2, "app/common/directives/my-directive.css", "app"},
+	}
 
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
-			rewrittenCSS := rewriteURLStatementsInCSS(tc.css, tc.base)
+			rewrittenCSS := rewriteURLStatementsInCSS(tc.css, tc.name, tc.base)
 			assert.Equal(t, tc.rewrittenCSS, rewrittenCSS)
 		})
 	}
 }
 
-func TestRewriteCSSUrlsDataURI(t *testing.T) {
-	rewrittenCSS := rewriteURLStatementsInCSS(inputCSS2, "common/directives/my-directive.css")
-	assert.Equal(t, outputCSS2, rewrittenCSS)
+func TestInlineCSSAssets(t *testing.T) {
+	setup()
+	defer teardown()
+	testutil.WriteTextFile(temppath, "small.svg", "<svg/>")
+	testutil.WriteTextFile(temppath, "large.png", strings.Repeat("x", 100))
+	cssFilepath := filepath.Join(temppath, "a.css")
+
+	cases := map[string]struct {
+		css      string
+		maxBytes int
+		expected string
+	}{
+		"small":    {`a { b: url(small.svg); }`, 10, `a { b: url("data:image/svg+xml;base64,PHN2Zy8+"); }`},
+		"query":    {`a { b: url('./small.svg?v=1'); }`, 10, `a { b: url("data:image/svg+xml;base64,PHN2Zy8+"); }`},
+		"large":    {`a { b: url(large.png); }`, 10, `a { b: url(large.png); }`},
+		"fragment": {`a { b: url(small.svg#icon); }`, 10, `a { b: url(small.svg#icon); }`},
+		"missing":  {`a { b: url(missing.png); }`, 10, `a { b: url(missing.png); }`},
+		"disabled": {`a { b: url(small.svg); }`, 0, `a { b: url(small.svg); }`},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, inlineCSSAssets(tc.css, cssFilepath, tc.maxBytes))
+		})
+	}
 }
 
 func TestRewriteURI(t *testing.T) {
@@ -78,8 +118,8 @@ func TestRewriteURI(t *testing.T) {
 func TestIsDataURI(t *testing.T) {
 	cases := map[string]bool{
 		"data:image/svg+xml;base64,PD94bWwgdmVyc2lvbj0iMS4wIiBlbm...": true,
-		"./some-background.png":                                       false,
-		"../../../../../some-background.png":                          false,
+		"./some-background.png":              false,
+		"../../../../../some-background.png": false,
 	}
 	for uri, expected := range cases {
 		t.Run(uri, func(t *testing.T) {
@@ -91,8 +131,10 @@ func TestIsDataURI(t *testing.T) {
 
 func TestExtractURI(t *testing.T) {
 	cases := map[string]string{
-		`url('./some-background.png')`: "./some-background.png",
-		`url("./some-background.png")`: "./some-background.png",
+		`url('./some-background.png')`:   "./some-background.png",
+		`url("./some-background.png")`:   "./some-background.png",
+		`url(./some-background.png)`:     "./some-background.png",
+		`url( './some-background.png' )`: "./some-background.png",
 	}
 	for uri, expected := range cases {
 		t.Run(uri, func(t *testing.T) {
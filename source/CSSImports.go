@@ -0,0 +1,161 @@
+package source
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"github.com/mrcrowl/swarm/util"
+)
+
+// cssImportPattern matches @import statements, e.g. @import url("a.css") screen; or @import 'b.css';
+var cssImportPattern = regexp.MustCompile(`@import\s+(?:url\(\s*(?:'([^']*)'|"([^"]*)"|([^'"\s)]*))\s*\)|'([^']*)'|"([^"]*)")\s*([^;]*);`)
+
+// cssImport is a single @import statement within a stylesheet
+type cssImport struct {
+	start, end int    // byte offsets of the statement
+	path       string // relative to the importing stylesheet, always beginning with ./ or ../
+	media      string // e.g. "screen and (max-width: 600px)", or "" for all media
+}
+
+// findCSSImports finds the @import statements of a stylesheet which refer to local files.  Imports of remote or
+// rooted urls are left for the browser to resolve
+func findCSSImports(css string) []*cssImport {
+	var imports []*cssImport
+	for _, match := range cssImportPattern.FindAllStringSubmatchIndex(css, -1) {
+		uri := ""
+		for group := 1; group <= 5; group++ {
+			if match[2*group] >= 0 {
+				uri = css[match[2*group]:match[2*group+1]]
+				break
+			}
+		}
+		if !isRelativeURI(uri) {
+			continue
+		}
+		if !strings.HasPrefix(uri, "./") && !strings.HasPrefix(uri, "../") {
+			uri = "./" + uri
+		}
+		media := strings.TrimSpace(css[match[12]:match[13]])
+		imports = append(imports, &cssImport{match[0], match[1], uri, media})
+	}
+	return imports
+}
+
+// ParseCSSImports gets the paths of the local stylesheets imported by a stylesheet, relative to the stylesheet
+func ParseCSSImports(css string) []string {
+	imports := findCSSImports(css)
+	paths := make([]string, len(imports))
+	for i, imp := range imports {
+		paths[i] = imp.path
+	}
+	return paths
+}
+
+// inlinedStylesheet is a stylesheet with its @import statements inlined, which records the stylesheet (and line) each
+// of its lines came from, for its source map
+type inlinedStylesheet struct {
+	name        string
+	sb          strings.Builder
+	sources     []*cssSource
+	origins     []cssLineOrigin // one for each line
+	atLineStart bool
+}
+
+// cssSource is one of the stylesheets inlined into an inlinedStylesheet
+type cssSource struct {
+	name      string
+	mapName   string     // root-relative path of the stylesheet's own source map, which its sources are relative to
+	sourceMap *MapConfig // the stylesheet's own source map, e.g. from a preprocessor, if it has one
+	mappings  [][][]int
+}
+
+type cssLineOrigin struct {
+	source int
+	line   int
+}
+
+// inlineCSSImports replaces the @import statements of a stylesheet with the contents of the stylesheets they import,
+// recursively, wrapping any with a media query in an @media block.  The url()s within imported stylesheets are
+// rebased, so they remain relative to the importing stylesheet.  An import which would form a cycle is dropped
+func inlineCSSImports(name string, absoluteFilepath string, css string) (*inlinedStylesheet, error) {
+	stylesheet := &inlinedStylesheet{name: name, atLineStart: true}
+	if err := stylesheet.inline(name, absoluteFilepath, css, map[string]bool{}); err != nil {
+		return nil, err
+	}
+	return stylesheet, nil
+}
+
+// CSS gets the stylesheet, with its imports inlined
+func (ss *inlinedStylesheet) CSS() string {
+	return ss.sb.String()
+}
+
+func (ss *inlinedStylesheet) inline(name string, absoluteFilepath string, css string, importing map[string]bool) error {
+	css, sourceMappingURL := extractCSSSourceMappingURL(css)
+	source := len(ss.sources)
+	ss.sources = append(ss.sources, newCSSSource(name, absoluteFilepath, sourceMappingURL))
+
+	importing[absoluteFilepath] = true
+	defer delete(importing, absoluteFilepath)
+
+	offset := 0
+	for _, imp := range findCSSImports(css) {
+		ss.write(css, offset, imp.start, name, source)
+		offset = imp.end
+
+		importedName := path.Join(path.Dir(name), imp.path)
+		importedFilepath := filepath.Join(filepath.Dir(absoluteFilepath), filepath.FromSlash(imp.path))
+		if importing[importedFilepath] {
+			continue
+		}
+		importedCSS, err := util.ReadContents(importedFilepath)
+		if err != nil {
+			return fmt.Errorf("%s: could not read @import '%s'", absoluteFilepath, imp.path)
+		}
+
+		line := strings.Count(css[:imp.start], "\n")
+		if imp.media != "" {
+			ss.writeText("@media "+imp.media+" {\n", source, line)
+		}
+		if err := ss.inline(importedName, importedFilepath, importedCSS, importing); err != nil {
+			return err
+		}
+		if imp.media != "" {
+			ss.writeText("\n", source, line)
+			ss.writeText("}", source, line) // maps to the @import statement, like the start of the block
+		}
+	}
+	ss.write(css, offset, len(css), name, source)
+	return nil
+}
+
+// write appends part of the css of one of the stylesheet's sources, with its url()s rebased to the stylesheet
+func (ss *inlinedStylesheet) write(css string, start int, end int, name string, source int) {
+	text := css[start:end]
+	if name != ss.name {
+		text = rewriteURLStatementsInCSS(text, name, path.Dir(ss.name))
+	}
+	ss.writeText(text, source, strings.Count(css[:start], "\n"))
+}
+
+// writeText appends text, which starts at a line of one of the stylesheet's sources
+func (ss *inlinedStylesheet) writeText(text string, source int, line int) {
+	for i, segment := range strings.Split(text, "\n") {
+		if i > 0 {
+			if ss.atLineStart {
+				ss.origins = append(ss.origins, cssLineOrigin{source, line + i - 1})
+			}
+			ss.sb.WriteByte('\n')
+			ss.atLineStart = true
+		}
+		if segment != "" {
+			if ss.atLineStart {
+				ss.origins = append(ss.origins, cssLineOrigin{source, line + i})
+				ss.atLineStart = false
+			}
+			ss.sb.WriteString(segment)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package source
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/mrcrowl/swarm/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCSSImports(t *testing.T) {
+	cases := map[string]struct {
+		css      string
+		expected []string
+	}{
+		"quoted":            {`@import "a.css";`, []string{"./a.css"}},
+		"single quoted":     {`@import './a.css';`, []string{"./a.css"}},
+		"url":               {`@import url("../a.css");`, []string{"../a.css"}},
+		"unquoted url":      {`@import url(a.css) screen;`, []string{"./a.css"}},
+		"media":             {`@import "a.css" screen and (max-width: 600px);`, []string{"./a.css"}},
+		"several":           {"@import 'a.css';\n@import 'b/c.css';\nbody {}", []string{"./a.css", "./b/c.css"}},
+		"remote":            {`@import url("https://fonts.example.com/css?family=Lato");`, []string{}},
+		"protocol-relative": {`@import "//cdn.example.com/a.css";`, []string{}},
+		"rooted":            {`@import "/styles/a.css";`, []string{}},
+		"none":              {`body { color: red; }`, []string{}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, ParseCSSImports(tc.css))
+		})
+	}
+}
+
+func TestInlineCSSImports(t *testing.T) {
+	setup()
+	defer teardown()
+	stylesDir := testutil.MakeSubdirectoryTree(temppath, "app/styles")
+	partialsDir := testutil.MakeSubdirectoryTree(temppath, "app/styles/partials")
+	testutil.WriteTextFile(partialsDir, "vars.css", ".vars { background: url('./bg.png'); }")
+	testutil.WriteTextFile(partialsDir, "print.css", "@import 'vars.css';\n.print { display: none; }")
+	testutil.WriteTextFile(partialsDir, "cycle.css", "@import '../main.css';\n.cycle {}")
+	mainFilepath := filepath.Join(stylesDir, "main.css")
+
+	cases := map[string]struct {
+		css      string
+		expected string
+	}{
+		"in order": {
+			"@import 'partials/vars.css';\nbody {}",
+			".vars { background: url('partials/bg.png'); }\nbody {}",
+		},
+		"media": {
+			"@import url(partials/vars.css) print;\nbody {}",
+			"@media print {\n.vars { background: url('partials/bg.png'); }\n}\nbody {}",
+		},
+		"nested": {
+			"@import './partials/print.css';",
+			".vars { background: url('partials/bg.png'); }\n.print { display: none; }",
+		},
+		"cycle": {
+			"@import 'partials/cycle.css';\n.main {}",
+			"\n.cycle {}\n.main {}",
+		},
+		"remote": {
+			"@import 'https://example.com/a.css';\n.main {}",
+			"@import 'https://example.com/a.css';\n.main {}",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			stylesheet, err := inlineCSSImports("app/styles/main.css", mainFilepath, tc.css)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, stylesheet.CSS())
+		})
+	}
+
+	_, err := inlineCSSImports("app/styles/main.css", mainFilepath, "@import 'missing.css';")
+	assert.NotNil(t, err)
+}
+
+func TestInjectedStylesheets(t *testing.T) {
+	fs := NewEmptyFileSet(createWorkspace())
+	for _, id := range []string{"app/main", "app/main.css", "app/theme.css", "app/partials/vars.css"} {
+		fs.Add(newFile(id, "c:\\"+id+filepath.Ext(id)))
+	}
+	fs.Get("app/main").ext = ".js"
+	fs.AddLink(NewDependencyLink("app/main", []string{"app/main.css", "app/theme.css"}))
+	fs.AddLink(NewDependencyLink("app/main.css", []string{"app/partials/vars.css"}))
+	fs.AddLink(NewDependencyLink("app/theme.css", []string{"app/partials/vars.css"}))
+
+	ids := func(files []*File) []string {
+		result := []string{}
+		for _, file := range files {
+			result = append(result, file.ID)
+		}
+		return result
+	}
+	assert.Equal(t, []string{"app/main.css", "app/theme.css"}, ids(fs.CSSImporters("app/partials/vars.css")))
+	assert.Equal(t, []string{"app/main.css", "app/theme.css"}, ids(fs.InjectedStylesheets("app/partials/vars.css")))
+	assert.Equal(t, []string{"app/main.css"}, ids(fs.InjectedStylesheets("app/main.css")))
+	assert.Empty(t, fs.InjectedStylesheets("app/missing.css"))
+}
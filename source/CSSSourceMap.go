@@ -0,0 +1,127 @@
+package source
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"github.com/mrcrowl/swarm/util"
+)
+
+var cssSourceMappingURLPattern = regexp.MustCompile(`/\*[#@]\s*sourceMappingURL=(\S+?)\s*\*/`)
+
+// extractCSSSourceMappingURL removes the sourceMappingURL comment from a stylesheet, returning the url it refers to
+func extractCSSSourceMappingURL(css string) (string, string) {
+	match := cssSourceMappingURLPattern.FindStringSubmatchIndex(css)
+	if match == nil {
+		return css, ""
+	}
+	return css[:match[0]] + css[match[1]:], css[match[2]:match[3]]
+}
+
+// newCSSSource creates a cssSource, loading the stylesheet's own source map from its sourceMappingURL, if it has one
+func newCSSSource(name string, absoluteFilepath string, sourceMappingURL string) *cssSource {
+	source := &cssSource{name: name}
+	if sourceMappingURL == "" {
+		return source
+	}
+
+	var contents string
+	var err error
+	if isDataURI(sourceMappingURL) {
+		contents, err = decodeDataURI(sourceMappingURL)
+		source.mapName = name
+	} else {
+		contents, err = util.ReadContents(filepath.Join(filepath.Dir(absoluteFilepath), filepath.FromSlash(sourceMappingURL)))
+		source.mapName = path.Join(path.Dir(name), sourceMappingURL)
+	}
+	if err != nil {
+		log.Printf("Failed to load source map: %s", sourceMappingURL)
+		return source
+	}
+	sourceMap, err := ParseSourceMapConfig(contents)
+	if err != nil {
+		log.Printf("ERROR parsing source map: %s", sourceMappingURL)
+		return source
+	}
+	if problem := util.InvalidMappings(sourceMap.Mappings); problem != "" {
+		log.Printf("ERROR in source map: %s: %s", sourceMappingURL, problem)
+		return source // as if it had no source map
+	}
+	source.sourceMap = sourceMap
+	source.mappings = util.DecodeMappings(sourceMap.Mappings)
+	return source
+}
+
+// decodeDataURI gets the contents of a data URI, e.g. data:application/json;base64,eyJ2ZXJzaW9uIjozfQ==
+func decodeDataURI(uri string) (string, error) {
+	comma := strings.Index(uri, ",")
+	if comma < 0 {
+		return "", errors.New("invalid data URI")
+	}
+	if strings.HasSuffix(uri[:comma], ";base64") {
+		data, err := base64.StdEncoding.DecodeString(uri[comma+1:])
+		return string(data), err
+	}
+	return url.PathUnescape(uri[comma+1:])
+}
+
+// upstreamSourceURL gets the url of one of the sources of the stylesheet's own source map, rooted at the server
+func (source *cssSource) upstreamSourceURL(index int) string {
+	sourcePath := source.sourceMap.Sources[index]
+	if source.sourceMap.SourceRoot != "" {
+		sourcePath = strings.TrimSuffix(source.sourceMap.SourceRoot, "/") + "/" + sourcePath
+	}
+	if !isRelativeURI(sourcePath) {
+		return sourcePath
+	}
+	return "/" + path.Join(path.Dir(source.mapName), sourcePath)
+}
+
+// sourceMapComment creates a comment containing an inline source map for the stylesheet, which maps each of its lines
+// to the line of the stylesheet it came from, or, where that stylesheet has a source map of its own, e.g. from a
+// preprocessor, to the lines that generated it
+func (ss *inlinedStylesheet) sourceMapComment() string {
+	sources := []string{}
+	sourceIndexes := make(map[string]int)
+	sourceIndex := func(url string) int {
+		index, found := sourceIndexes[url]
+		if !found {
+			index = len(sources)
+			sourceIndexes[url] = index
+			sources = append(sources, url)
+		}
+		return index
+	}
+
+	lines := make([][][]int, len(ss.origins))
+	for i, origin := range ss.origins {
+		source := ss.sources[origin.source]
+		if source.sourceMap == nil {
+			lines[i] = [][]int{{0, sourceIndex("/" + source.name), origin.line, 0}}
+			continue
+		}
+		if origin.line >= len(source.mappings) {
+			continue
+		}
+		for _, segment := range source.mappings[origin.line] {
+			if len(segment) >= 4 && segment[1] >= 0 && segment[1] < len(source.sourceMap.Sources) {
+				lines[i] = append(lines[i], []int{segment[0], sourceIndex(source.upstreamSourceURL(segment[1])), segment[2], segment[3]})
+			}
+		}
+	}
+
+	mapJSON, _ := json.Marshal(&MapConfig{
+		Version:  3,
+		File:     path.Base(ss.name),
+		Sources:  sources,
+		Names:    []string{},
+		Mappings: util.EncodeMappings(lines),
+	})
+	return "/*# sourceMappingURL=data:application/json;charset=utf-8;base64," + base64.StdEncoding.EncodeToString(mapJSON) + " */"
+}
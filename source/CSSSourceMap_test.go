@@ -0,0 +1,99 @@
+package source
+
+import (
+	"encoding/base64"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mrcrowl/swarm/testutil"
+	"github.com/mrcrowl/swarm/util"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func parseSourceMapComment(t *testing.T, comment string) *MapConfig {
+	const prefix = "/*# sourceMappingURL=data:application/json;charset=utf-8;base64,"
+	assert.True(t, strings.HasPrefix(comment, prefix))
+	mapJSON, err := base64.StdEncoding.DecodeString(strings.TrimSuffix(strings.TrimPrefix(comment, prefix), " */"))
+	assert.Nil(t, err)
+	sourceMap, err := ParseSourceMapConfig(string(mapJSON))
+	assert.Nil(t, err)
+	return sourceMap
+}
+
+func TestExtractCSSSourceMappingURL(t *testing.T) {
+	css, url := extractCSSSourceMappingURL("a {}\n/*# sourceMappingURL=a.css.map */")
+	assert.Equal(t, "a {}\n", css)
+	assert.Equal(t, "a.css.map", url)
+
+	css, url = extractCSSSourceMappingURL("a {}")
+	assert.Equal(t, "a {}", css)
+	assert.Equal(t, "", url)
+}
+
+func TestCSSSourceMapOfInlinedImports(t *testing.T) {
+	setup()
+	defer teardown()
+	stylesDir := testutil.MakeSubdirectoryTree(temppath, "app/styles")
+	testutil.WriteTextFile(stylesDir, "print.css", ".print {\n\tdisplay: none;\n}")
+
+	stylesheet, err := inlineCSSImports("app/styles/main.css", filepath.Join(stylesDir, "main.css"), "body {}\n@import 'print.css' print;\n.main {}")
+	assert.Nil(t, err)
+	assert.Equal(t, "body {}\n@media print {\n.print {\n\tdisplay: none;\n}\n}\n.main {}", stylesheet.CSS())
+
+	sourceMap := parseSourceMapComment(t, stylesheet.sourceMapComment())
+	assert.Equal(t, "main.css", sourceMap.File)
+	assert.Equal(t, []string{"/app/styles/main.css", "/app/styles/print.css"}, sourceMap.Sources)
+	assert.Equal(t, [][][]int{
+		{{0, 0, 0, 0}}, // body {}
+		{{0, 0, 1, 0}}, // @media print {
+		{{0, 1, 0, 0}}, // .print {
+		{{0, 1, 1, 0}},
+		{{0, 1, 2, 0}},
+		{{0, 0, 1, 0}}, // }
+		{{0, 0, 2, 0}}, // .main {}
+	}, util.DecodeMappings(sourceMap.Mappings))
+}
+
+func TestCSSSourceMapHonoursUpstreamMap(t *testing.T) {
+	setup()
+	defer teardown()
+	stylesDir := testutil.MakeSubdirectoryTree(temppath, "app/styles")
+	testutil.WriteTextFile(stylesDir, "main.css.map", `{"version":3,"sourceRoot":"../scss","sources":["main.scss"],"names":[],"mappings":"AAAA;AAEE"}`)
+
+	stylesheet, err := inlineCSSImports("app/styles/main.css", filepath.Join(stylesDir, "main.css"), "a {}\nb {}\n/*# sourceMappingURL=main.css.map */")
+	assert.Nil(t, err)
+	assert.Equal(t, "a {}\nb {}\n", stylesheet.CSS())
+
+	sourceMap := parseSourceMapComment(t, stylesheet.sourceMapComment())
+	assert.Equal(t, []string{"/app/scss/main.scss"}, sourceMap.Sources)
+	assert.Equal(t, [][][]int{{{0, 0, 0, 0}}, {{0, 0, 2, 2}}}, util.DecodeMappings(sourceMap.Mappings))
+}
+
+func TestCSSSourceMapInlineUpstreamMap(t *testing.T) {
+	upstream := base64.StdEncoding.EncodeToString([]byte(`{"version":3,"sources":["https://example.com/a.scss"],"names":[],"mappings":"AAAA"}`))
+	stylesheet, err := inlineCSSImports("app/a.css", "/app/a.css", "a {}\n/*# sourceMappingURL=data:application/json;base64,"+upstream+" */")
+	assert.Nil(t, err)
+
+	sourceMap := parseSourceMapComment(t, stylesheet.sourceMapComment())
+	assert.Equal(t, []string{"https://example.com/a.scss"}, sourceMap.Sources)
+}
+
+func TestCSSSourceMapIgnoresInvalidUpstreamMap(t *testing.T) {
+	setup()
+	defer teardown()
+	stylesDir := testutil.MakeSubdirectoryTree(temppath, "app/styles")
+	testutil.WriteTextFile(stylesDir, "a.css.map", `{"version":3,"sources":["a.scss"],"names":[],"mappings":"AA!A"}`)
+	testutil.WriteTextFile(stylesDir, "b.css.map", `{"version":3,"sources":["b.scss"],"names":[],"mappings":"ADAA"}`)
+
+	stylesheet, err := inlineCSSImports("app/styles/a.css", filepath.Join(stylesDir, "a.css"), "a {}\n/*# sourceMappingURL=a.css.map */")
+	assert.Nil(t, err)
+	sourceMap := parseSourceMapComment(t, stylesheet.sourceMapComment())
+	assert.Equal(t, []string{"/app/styles/a.css"}, sourceMap.Sources) // as if it had no source map
+
+	stylesheet, err = inlineCSSImports("app/styles/b.css", filepath.Join(stylesDir, "b.css"), "b {}\n/*# sourceMappingURL=b.css.map */")
+	assert.Nil(t, err)
+	sourceMap = parseSourceMapComment(t, stylesheet.sourceMapComment())
+	assert.Equal(t, []string{}, sourceMap.Sources) // the segment's source index is -1
+}
@@ -0,0 +1,102 @@
+package source
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CommonJSFileContents describes a CommonJS module (one using require and module.exports), wrapped in System.register
+type CommonJSFileContents struct {
+	imports          []string
+	lines            []string
+	sourceMappingURL string
+}
+
+// BundleLines returns a list of lines ready to include in a SystemJSBundle
+func (cjsfc *CommonJSFileContents) BundleLines() []string {
+	return cjsfc.lines
+}
+
+// SourceMappingURL returns the file's sourceMappingURL, if any
+func (cjsfc *CommonJSFileContents) SourceMappingURL() string {
+	return cjsfc.sourceMappingURL
+}
+
+// commonJSHeader opens the execute function with the module, exports and require variables of a CommonJS module.
+// A required dependency is resolved from its setter, using its default export if it has an __useDefault export of any
+// value, as CommonJS, JSON and text modules do
+const commonJSHeader = ` var __dependencies = {}; return { setters: [%s], execute: function () { ` +
+	`var module = { exports: {} }, exports = module.exports; ` +
+	`var require = function (id) { if (!(id in __dependencies)) { throw new Error("Module " + id + " was not found as a static dependency of ` + "%s" + `"); } ` +
+	`var dependency = __dependencies[id]; return dependency && "__useDefault" in dependency ? dependency["default"] : dependency; }; ` +
+	`(function (require, exports, module) { `
+
+// commonJSClosingLines export module.exports as both the default export and __useDefault, then close the wrapper
+// function, the execute function, the returned declaration and the System.register call
+var commonJSClosingLines = []string{
+	"}).call(exports, require, exports, module);",
+	"\t\t\texports_1(\"default\", module.exports);",
+	"\t\t\texports_1(\"__useDefault\", module.exports);",
+	"\t\t}",
+	"\t};",
+	"});",
+}
+
+// ParseCommonJSDependencies finds the (unquoted) dependencies of a CommonJS module from its require("...") calls.
+// Returns false if the javascript never calls require or refers to module.exports or exports
+func ParseCommonJSDependencies(javascript string) ([]string, bool) {
+	tokens := tokenizeJavascript(javascript)
+	var dependencies []string
+	seen := map[string]bool{}
+	isCommonJS := false
+	for i, token := range tokens {
+		if token.kind != jsIdentifier || i > 0 && tokens[i-1].is(".") {
+			continue
+		}
+
+		switch token.text {
+		case "require":
+			if i+3 < len(tokens) && tokens[i+1].is("(") && tokens[i+2].kind == jsString && tokens[i+3].is(")") {
+				isCommonJS = true
+				dependency := unquoteJS(tokens[i+2])
+				if !seen[dependency] {
+					seen[dependency] = true
+					dependencies = append(dependencies, dependency)
+				}
+			}
+		case "module":
+			if i+2 < len(tokens) && tokens[i+1].is(".") && tokens[i+2].is("exports") {
+				isCommonJS = true
+			}
+		case "exports":
+			if i+1 < len(tokens) && (tokens[i+1].is(".") || tokens[i+1].is("[")) {
+				isCommonJS = true
+			}
+		}
+	}
+	return dependencies, isCommonJS
+}
+
+// parseCommonJSFileContents wraps the lines of a CommonJS module in System.register, keeping the same line structure
+func parseCommonJSFileContents(name string, lines []string, metadata *jsFileMetadata) *CommonJSFileContents {
+	body := chooseBodyLines(lines, 0, metadata.FoundSourceMap)
+	setters := make([]string, len(metadata.Imports))
+	for i, quotedImport := range metadata.Imports {
+		setters[i] = "function (__module) { __dependencies[" + quotedImport + "] = __module; }"
+	}
+
+	wrapped := make([]string, 0, len(body)+len(commonJSClosingLines))
+	wrapped = append(wrapped, body...)
+	if len(wrapped) == 0 {
+		wrapped = append(wrapped, "")
+	}
+	header := getRegisterLineForBundle(name, metadata.Imports) + fmt.Sprintf(commonJSHeader, strings.Join(setters, ", "), name+".js")
+	wrapped[0] = header + strings.TrimPrefix(wrapped[0], byteOrderMark)
+	wrapped = append(wrapped, commonJSClosingLines...)
+
+	return &CommonJSFileContents{
+		imports:          metadata.Imports,
+		lines:            wrapped,
+		sourceMappingURL: metadata.SourceMappingURL,
+	}
+}
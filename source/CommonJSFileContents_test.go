@@ -0,0 +1,44 @@
+package source
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCommonJSDependencies(t *testing.T) {
+	cases := map[string]struct {
+		source       string
+		dependencies []string
+		isCommonJS   bool
+	}{
+		"script": {
+			source: "var a = window.require;\nfunction f(exports) { return exports; }",
+		},
+		"requires": {
+			source:       "var a = require('./a');\nvar b = require(\"./b\"), a2 = require('./a');",
+			dependencies: []string{"./a", "./b"},
+			isCommonJS:   true,
+		},
+		"dynamic require isn't a dependency": {
+			source:     "var name = './a';\nmodule.exports = require(name);",
+			isCommonJS: true,
+		},
+		"exports only": {
+			source:     "exports.a = 1;",
+			isCommonJS: true,
+		},
+		"commented and quoted requires": {
+			source: "// require('./a')\nvar s = \"require('./b')\";",
+		},
+		"member require": {
+			source: "loader.require('./a');",
+		},
+	}
+
+	for name, c := range cases {
+		dependencies, isCommonJS := ParseCommonJSDependencies(c.source)
+		assert.Equal(t, c.isCommonJS, isCommonJS, name)
+		assert.Equal(t, c.dependencies, dependencies, name)
+	}
+}
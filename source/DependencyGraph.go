@@ -0,0 +1,194 @@
+package source
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Supported output formats for a DependencyGraph
+const (
+	GraphFormatDOT     = "dot"
+	GraphFormatJSON    = "json"
+	GraphFormatMermaid = "mermaid"
+)
+
+// DependencyGraph is a directed graph of IDs (files or modules), where each node may belong to a group
+type DependencyGraph struct {
+	groups map[string]string
+	edges  map[string][]string
+}
+
+// NewDependencyGraph creates an empty DependencyGraph
+func NewDependencyGraph() *DependencyGraph {
+	return &DependencyGraph{
+		groups: make(map[string]string),
+		edges:  make(map[string][]string),
+	}
+}
+
+// AddNode adds a node to the graph, optionally within a named group
+func (graph *DependencyGraph) AddNode(id string, group string) {
+	if _, found := graph.groups[id]; !found {
+		graph.groups[id] = group
+	}
+}
+
+// AddEdge adds a dependency from one node to another
+func (graph *DependencyGraph) AddEdge(fromID string, toID string) {
+	for _, existingID := range graph.edges[fromID] {
+		if existingID == toID {
+			return
+		}
+	}
+	graph.edges[fromID] = append(graph.edges[fromID], toID)
+}
+
+// Contains tests whether the graph contains a node
+func (graph *DependencyGraph) Contains(id string) bool {
+	_, found := graph.groups[id]
+	return found
+}
+
+// Group gets the name of the group a node belongs to
+func (graph *DependencyGraph) Group(id string) string {
+	return graph.groups[id]
+}
+
+// Nodes gets the IDs of all nodes, sorted
+func (graph *DependencyGraph) Nodes() []string {
+	ids := make([]string, 0, len(graph.groups))
+	for id := range graph.groups {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Edges gets the sorted IDs of the nodes that a node depends upon
+func (graph *DependencyGraph) Edges(id string) []string {
+	edges := append([]string(nil), graph.edges[id]...)
+	sort.Strings(edges)
+	return edges
+}
+
+// Format renders the graph as Graphviz DOT, a JSON adjacency list or a Mermaid flowchart
+func (graph *DependencyGraph) Format(format string) (string, error) {
+	switch format {
+	case GraphFormatDOT:
+		return graph.formatDOT(), nil
+	case GraphFormatJSON:
+		return graph.formatJSON()
+	case GraphFormatMermaid:
+		return graph.formatMermaid(), nil
+	}
+	return "", fmt.Errorf("unknown graph format '%s' (expected %s, %s or %s)", format, GraphFormatDOT, GraphFormatJSON, GraphFormatMermaid)
+}
+
+// groupedNodes gets the sorted group names, and the sorted nodes within each group
+func (graph *DependencyGraph) groupedNodes() ([]string, map[string][]string) {
+	nodesByGroup := make(map[string][]string)
+	for _, id := range graph.Nodes() {
+		group := graph.groups[id]
+		nodesByGroup[group] = append(nodesByGroup[group], id)
+	}
+
+	groups := make([]string, 0, len(nodesByGroup))
+	for group := range nodesByGroup {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	return groups, nodesByGroup
+}
+
+func (graph *DependencyGraph) formatDOT() string {
+	var sb strings.Builder
+	sb.WriteString("digraph swarm {\n")
+	sb.WriteString("\trankdir=LR;\n")
+	sb.WriteString("\tnode [shape=box];\n")
+
+	groups, nodesByGroup := graph.groupedNodes()
+	for i, group := range groups {
+		indent := "\t"
+		if group != "" {
+			fmt.Fprintf(&sb, "\tsubgraph cluster_%d {\n", i)
+			fmt.Fprintf(&sb, "\t\tlabel=%s;\n", quoteDOT(group))
+			indent = "\t\t"
+		}
+		for _, id := range nodesByGroup[group] {
+			fmt.Fprintf(&sb, "%s%s;\n", indent, quoteDOT(id))
+		}
+		if group != "" {
+			sb.WriteString("\t}\n")
+		}
+	}
+
+	for _, id := range graph.Nodes() {
+		for _, dependencyID := range graph.Edges(id) {
+			fmt.Fprintf(&sb, "\t%s -> %s;\n", quoteDOT(id), quoteDOT(dependencyID))
+		}
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+func (graph *DependencyGraph) formatJSON() (string, error) {
+	adjacency := make(map[string][]string, len(graph.groups))
+	for _, id := range graph.Nodes() {
+		adjacency[id] = graph.Edges(id)
+		if adjacency[id] == nil {
+			adjacency[id] = []string{}
+		}
+	}
+
+	jsonBytes, err := json.MarshalIndent(adjacency, "", "\t")
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes) + "\n", nil
+}
+
+func (graph *DependencyGraph) formatMermaid() string {
+	var sb strings.Builder
+	sb.WriteString("graph LR\n")
+
+	nodeKeys := make(map[string]string, len(graph.groups))
+	for i, id := range graph.Nodes() {
+		nodeKeys[id] = fmt.Sprintf("n%d", i)
+	}
+
+	groups, nodesByGroup := graph.groupedNodes()
+	for i, group := range groups {
+		indent := "\t"
+		if group != "" {
+			fmt.Fprintf(&sb, "\tsubgraph g%d[\"%s\"]\n", i, escapeMermaid(group))
+			indent = "\t\t"
+		}
+		for _, id := range nodesByGroup[group] {
+			fmt.Fprintf(&sb, "%s%s[\"%s\"]\n", indent, nodeKeys[id], escapeMermaid(id))
+		}
+		if group != "" {
+			sb.WriteString("\tend\n")
+		}
+	}
+
+	for _, id := range graph.Nodes() {
+		for _, dependencyID := range graph.Edges(id) {
+			if dependencyKey, found := nodeKeys[dependencyID]; found {
+				fmt.Fprintf(&sb, "\t%s --> %s\n", nodeKeys[id], dependencyKey)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// quoteDOT quotes an ID for use in a Graphviz DOT file
+func quoteDOT(id string) string {
+	return "\"" + strings.Replace(id, "\"", "\\\"", -1) + "\""
+}
+
+// escapeMermaid escapes a label for use in a Mermaid flowchart
+func escapeMermaid(label string) string {
+	return strings.Replace(label, "\"", "#quot;", -1)
+}
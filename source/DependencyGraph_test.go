@@ -0,0 +1,77 @@
+package source
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createSampleDependencyGraph() *DependencyGraph {
+	graph := NewDependencyGraph()
+	graph.AddNode("app/App", "app")
+	graph.AddNode("app/Util", "app")
+	graph.AddNode("lib/Lib", "lib")
+	graph.AddEdge("app/App", "app/Util")
+	graph.AddEdge("app/App", "lib/Lib")
+	graph.AddEdge("app/App", "lib/Lib")
+	return graph
+}
+
+func TestDependencyGraphFormatJSON(t *testing.T) {
+	output, err := createSampleDependencyGraph().Format(GraphFormatJSON)
+	assert.Nil(t, err)
+	expected := `{
+	"app/App": [
+		"app/Util",
+		"lib/Lib"
+	],
+	"app/Util": [],
+	"lib/Lib": []
+}
+`
+	assert.Equal(t, expected, output)
+}
+
+func TestDependencyGraphFormatDOT(t *testing.T) {
+	output, err := createSampleDependencyGraph().Format(GraphFormatDOT)
+	assert.Nil(t, err)
+	expected := `digraph swarm {
+	rankdir=LR;
+	node [shape=box];
+	subgraph cluster_0 {
+		label="app";
+		"app/App";
+		"app/Util";
+	}
+	subgraph cluster_1 {
+		label="lib";
+		"lib/Lib";
+	}
+	"app/App" -> "app/Util";
+	"app/App" -> "lib/Lib";
+}
+`
+	assert.Equal(t, expected, output)
+}
+
+func TestDependencyGraphFormatMermaid(t *testing.T) {
+	output, err := createSampleDependencyGraph().Format(GraphFormatMermaid)
+	assert.Nil(t, err)
+	expected := `graph LR
+	subgraph g0["app"]
+		n0["app/App"]
+		n1["app/Util"]
+	end
+	subgraph g1["lib"]
+		n2["lib/Lib"]
+	end
+	n0 --> n1
+	n0 --> n2
+`
+	assert.Equal(t, expected, output)
+}
+
+func TestDependencyGraphFormatUnknown(t *testing.T) {
+	_, err := createSampleDependencyGraph().Format("svg")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,874 @@
+package source
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"github.com/mrcrowl/swarm/util"
+)
+
+// ESModule is an ES module, transformed into System.register format
+type ESModule struct {
+	Dependencies []string // the (unquoted) static dependencies, in order of first appearance
+	Lines        []string // the transformed lines, each corresponding to a line of the original, plus closing lines
+}
+
+// esModuleSetterParam is the name of the parameter through which each setter receives a dependency's exports
+const esModuleSetterParam = "__module"
+
+// esModuleUpdatedValue is the name of the variable which holds the result of a postfix update of an exported binding,
+// e.g. count++, while the updated binding is exported
+const esModuleUpdatedValue = "__updated"
+
+// esModuleClosingLines closes the execute function, the returned declaration and the System.register call
+var esModuleClosingLines = []string{"\t\t}", "\t};", "});"}
+
+type textEdit struct {
+	start       int
+	end         int
+	replacement string
+}
+
+// esDeclaration is a top-level declaration whose bindings might be exported
+type esDeclaration struct {
+	hoisted     bool // function declarations are exported before the module executes, since they are hoisted
+	mutable     bool // var and let bindings might be reassigned, and exported again
+	insertAt    int  // offset after which the declaration has been evaluated, and its bindings can be exported
+	insertAfter string
+	first       int // the declaration's first and last tokens
+	last        int
+}
+
+type esLocalExport struct {
+	local    string
+	exported string
+}
+
+// esModuleTransformer rewrites the import and export statements of an ES module, keeping every other line and column
+// where it was, so that any upstream source map still lines up
+type esModuleTransformer struct {
+	src           string
+	tokens        []*jsToken
+	edits         []textEdit
+	isModule      bool
+	isAsync       bool
+	dependencies  []string
+	setters       map[string][]string
+	setterExports map[string][]string
+	bindings      []string
+	importedFrom  map[string]string
+	declarations  map[string]*esDeclaration
+	localExports  []esLocalExport
+	startExports  []string
+	endExports    []string
+	hasUpdates    bool // whether a postfix update of an exported binding needs esModuleUpdatedValue
+}
+
+// ParseESModuleDependencies finds the static dependencies of an ES module.  Returns false if the javascript contains no
+// import or export declarations
+func ParseESModuleDependencies(javascript string) ([]string, bool) {
+	t := newESModuleTransformer(javascript)
+	t.run()
+	return t.dependencies, t.isModule
+}
+
+// TransformESModule transforms an ES module into a named System.register module.  Returns nil if the javascript
+// contains no import or export declarations
+func TransformESModule(name string, javascript string) *ESModule {
+	t := newESModuleTransformer(javascript)
+	t.run()
+	if !t.isModule {
+		return nil
+	}
+	return &ESModule{
+		Dependencies: t.dependencies,
+		Lines:        t.output(name),
+	}
+}
+
+func newESModuleTransformer(javascript string) *esModuleTransformer {
+	return &esModuleTransformer{
+		src:           javascript,
+		tokens:        tokenizeJavascript(javascript),
+		setters:       map[string][]string{},
+		setterExports: map[string][]string{},
+		importedFrom:  map[string]string{},
+		declarations:  map[string]*esDeclaration{},
+	}
+}
+
+func (t *esModuleTransformer) token(i int) *jsToken {
+	if i >= 0 && i < len(t.tokens) {
+		return t.tokens[i]
+	}
+	return nil
+}
+
+func (t *esModuleTransformer) run() {
+	depth := 0
+	for i := 0; i < len(t.tokens); i++ {
+		token := t.tokens[i]
+		if token.kind == jsIdentifier && t.token(i-1).is(".") {
+			continue // a property, e.g. loader.import(...)
+		}
+
+		switch {
+		case token.is("import") && t.token(i+1).is("(") && !t.token(t.matching(i+1)+1).is("{"):
+			t.replace(token.start, token.end, "context_1.import")
+		case token.is("import") && t.token(i+1).is(".") && t.token(i+2).is("meta"):
+			t.replace(token.start, t.tokens[i+2].end, "context_1.meta")
+			i += 2
+		case depth == 0 && token.is("import"):
+			t.isModule = true
+			i = t.importDeclaration(i)
+		case depth == 0 && token.is("export"):
+			t.isModule = true
+			i = t.exportDeclaration(i)
+		case depth == 0 && isDeclarationKeyword(token) && t.atStatementStart(i):
+			t.declaration(i)
+		case depth == 0 && token.is("await"):
+			t.isAsync = true
+		case token.isOpening():
+			depth++
+		case token.isClosing():
+			depth--
+		}
+	}
+	t.resolveLocalExports()
+}
+
+func isDeclarationKeyword(token *jsToken) bool {
+	return token.is("var") || token.is("let") || token.is("const") || token.is("function") || token.is("async") || token.is("class")
+}
+
+func (t *esModuleTransformer) atStatementStart(i int) bool {
+	previous := t.token(i - 1)
+	return previous == nil || previous.is(";") || previous.is("}") || t.tokens[i].newlineBefore
+}
+
+// importDeclaration handles import "m", import d from "m", import * as ns from "m", import { a, b as c } from "m" and
+// combinations thereof.  Returns the index of the declaration's last token
+func (t *esModuleTransformer) importDeclaration(i int) int {
+	var assignments []string
+	j := i + 1
+	if token := t.token(j); token != nil && token.kind == jsIdentifier && !token.is("from") {
+		assignments = append(assignments, t.importBinding(token.text, `["default"]`))
+		j++
+		if t.token(j).is(",") {
+			j++
+		}
+	}
+	if t.token(j).is("*") && t.token(j+1).is("as") && t.token(j+2) != nil {
+		assignments = append(assignments, t.importBinding(t.tokens[j+2].text, ""))
+		j += 3
+	}
+	if t.token(j).is("{") {
+		var specifiers []esLocalExport
+		specifiers, j = t.specifiers(j)
+		for _, specifier := range specifiers {
+			// for imports, the "local" name is the name of the dependency's export
+			assignments = append(assignments, t.importBinding(specifier.exported, "["+quoteJS(specifier.local)+"]"))
+		}
+	}
+	if t.token(j).is("from") {
+		j++
+	}
+
+	end := j
+	if token := t.token(j); token != nil && token.kind == jsString {
+		dependency := t.addDependency(token.text)
+		t.setters[dependency] = append(t.setters[dependency], assignments...)
+		end = t.skipImportAttributes(j)
+	}
+	end = t.includeSemicolon(end)
+	t.blank(t.tokens[i].start, t.tokens[end].end)
+	return end
+}
+
+func (t *esModuleTransformer) importBinding(local string, member string) string {
+	t.bindings = append(t.bindings, local)
+	return local + " = " + esModuleSetterParam + member + ";"
+}
+
+// exportDeclaration handles each form of export declaration.  Returns the index of the last token handled, which may
+// be the export keyword itself, when the rest of the declaration is left in place
+func (t *esModuleTransformer) exportDeclaration(i int) int {
+	next := t.token(i + 1)
+	switch {
+	case next.is("*"):
+		return t.exportFrom(i, i+1)
+	case next.is("{"):
+		specifiers, j := t.specifiers(i + 1)
+		if t.token(j).is("from") {
+			return t.exportFrom(i, i+1)
+		}
+		t.localExports = append(t.localExports, specifiers...)
+		end := t.includeSemicolon(j - 1)
+		t.blank(t.tokens[i].start, t.tokens[end].end)
+		return end
+	case next.is("default"):
+		return t.exportDefault(i)
+	case isDeclarationKeyword(next):
+		t.blank(t.tokens[i].start, next.start)
+		for _, name := range t.declaration(i + 1) {
+			t.localExports = append(t.localExports, esLocalExport{name, name})
+		}
+		return i
+	}
+	return i
+}
+
+// exportFrom handles export * from "m", export * as ns from "m" and export { a, b as c } from "m"
+func (t *esModuleTransformer) exportFrom(i int, j int) int {
+	var statements []string
+	switch {
+	case t.token(j).is("*") && t.token(j+1).is("as") && t.token(j+2) != nil:
+		statements = append(statements, exportCall(t.tokens[j+2].text, esModuleSetterParam))
+		j += 3
+	case t.token(j).is("*"):
+		statements = append(statements, "for (var __name in "+esModuleSetterParam+") if (__name !== \"default\") exports_1(__name, "+esModuleSetterParam+"[__name]);")
+		j++
+	default:
+		var specifiers []esLocalExport
+		specifiers, j = t.specifiers(j)
+		for _, specifier := range specifiers {
+			statements = append(statements, exportCall(specifier.exported, esModuleSetterParam+"["+quoteJS(specifier.local)+"]"))
+		}
+	}
+
+	end := j
+	if t.token(j).is("from") && t.token(j+1) != nil && t.tokens[j+1].kind == jsString {
+		dependency := t.addDependency(t.tokens[j+1].text)
+		t.setterExports[dependency] = append(t.setterExports[dependency], statements...)
+		end = t.skipImportAttributes(j + 1)
+	}
+	end = t.includeSemicolon(end)
+	t.blank(t.tokens[i].start, t.tokens[end].end)
+	return end
+}
+
+// exportDefault handles export default function/class declarations, as well as export default <expression>
+func (t *esModuleTransformer) exportDefault(i int) int {
+	j := i + 2
+	isFunction := t.token(j).is("function") || t.token(j).is("async") && t.token(j+1).is("function")
+	isClass := t.token(j).is("class")
+	if isFunction || isClass {
+		if name := t.declaredName(j); name != "" {
+			t.blank(t.tokens[i].start, t.tokens[j].start)
+			t.declaration(j)
+			t.localExports = append(t.localExports, esLocalExport{name, "default"})
+			return i + 1
+		}
+		// an anonymous function or class, which is an expression
+		end := t.bodyEnd(j)
+		t.replace(t.tokens[i].start, t.tokens[j].start, "exports_1(\"default\", ")
+		t.insert(t.tokens[end].end, ");")
+		return i + 1
+	}
+
+	end := t.statementEnd(j)
+	t.replace(t.tokens[i].start, t.tokens[j].start, "exports_1(\"default\", ")
+	if t.tokens[end].is(";") && end > j {
+		t.insert(t.tokens[end-1].end, ")")
+	} else {
+		t.insert(t.tokens[end].end, ")")
+	}
+	return i + 1
+}
+
+// declaration records the bindings of a top-level var, let, const, function or class declaration starting at token i,
+// so that they can be exported.  Returns the names declared
+func (t *esModuleTransformer) declaration(i int) []string {
+	token := t.tokens[i]
+	switch {
+	case token.is("function") || token.is("async") && t.token(i+1).is("function"):
+		name := t.declaredName(i)
+		if name == "" {
+			return nil
+		}
+		t.declarations[name] = &esDeclaration{hoisted: true}
+		return []string{name}
+	case token.is("class"):
+		name := t.declaredName(i)
+		if name == "" {
+			return nil
+		}
+		end := t.bodyEnd(i)
+		t.declarations[name] = &esDeclaration{insertAt: t.tokens[end].end, insertAfter: " "}
+		return []string{name}
+	case token.is("var") || token.is("let") || token.is("const"):
+		end := t.statementEnd(i)
+		declaration := &esDeclaration{mutable: !token.is("const"), insertAt: t.tokens[end].end, insertAfter: " ", first: i, last: end}
+		if !t.tokens[end].is(";") {
+			declaration.insertAfter = "; "
+		}
+		names := t.declaredNames(i, end)
+		for _, name := range names {
+			t.declarations[name] = declaration
+		}
+		return names
+	}
+	return nil
+}
+
+// declaredName finds the name of a function or class declaration, or returns "" if it is anonymous
+func (t *esModuleTransformer) declaredName(i int) string {
+	for j := i; j < len(t.tokens); j++ {
+		token := t.tokens[j]
+		switch {
+		case token.is("async") || token.is("function") || token.is("class") || token.is("*"):
+			continue
+		case token.kind == jsIdentifier && !token.is("extends"):
+			return token.text
+		}
+		return ""
+	}
+	return ""
+}
+
+// declaredNames finds the names bound by a var, let or const declaration, including those in destructuring patterns
+func (t *esModuleTransformer) declaredNames(keyword int, end int) []string {
+	var names []string
+	for j := keyword + 1; j <= end; {
+		token := t.tokens[j]
+		switch {
+		case token.kind == jsIdentifier:
+			names = append(names, token.text)
+			j++
+		case token.is("{") || token.is("["):
+			var patternNames []string
+			patternNames, j = t.patternNames(j)
+			names = append(names, patternNames...)
+		default:
+			return names
+		}
+
+		// skip any initialiser, up to the next declarator
+		j = t.skipExpression(j, end)
+		if j <= end && t.tokens[j].is(",") {
+			j++
+		} else {
+			break
+		}
+	}
+	return names
+}
+
+// patternNames finds the names bound by a destructuring pattern.  Returns the index beyond the pattern
+func (t *esModuleTransformer) patternNames(open int) ([]string, int) {
+	var names []string
+	depth := 0
+	for j := open; j < len(t.tokens); j++ {
+		token := t.tokens[j]
+		switch {
+		case token.isOpening():
+			depth++
+		case token.isClosing():
+			depth--
+			if depth == 0 {
+				return names, j + 1
+			}
+		case token.is("="):
+			j = t.skipExpression(j+1, len(t.tokens)-1) - 1
+		case token.kind == jsIdentifier:
+			if next := t.token(j + 1); next.is(",") || next.is("=") || next.isClosing() {
+				names = append(names, token.text)
+			}
+		}
+	}
+	return names, len(t.tokens)
+}
+
+// skipExpression skips tokens up to the next comma or closing bracket which isn't nested
+func (t *esModuleTransformer) skipExpression(j int, end int) int {
+	depth := 0
+	for ; j <= end; j++ {
+		token := t.tokens[j]
+		switch {
+		case token.isOpening():
+			depth++
+		case token.isClosing():
+			if depth == 0 {
+				return j
+			}
+			depth--
+		case depth == 0 && token.is(","):
+			return j
+		}
+	}
+	return j
+}
+
+// specifiers reads a braced list of specifiers, e.g. { a, b as c, "d-e" as f }.  Returns the index beyond the list
+func (t *esModuleTransformer) specifiers(open int) ([]esLocalExport, int) {
+	var specifiers []esLocalExport
+	j := open + 1
+	for ; j < len(t.tokens) && !t.tokens[j].is("}"); j++ {
+		token := t.tokens[j]
+		if token.is(",") {
+			continue
+		}
+		name := unquoteJS(token)
+		specifier := esLocalExport{name, name}
+		if t.token(j+1).is("as") && t.token(j+2) != nil {
+			specifier.exported = unquoteJS(t.tokens[j+2])
+			j += 2
+		}
+		specifiers = append(specifiers, specifier)
+	}
+	return specifiers, j + 1
+}
+
+// skipImportAttributes skips any with { type: "json" } clause following the module specifier at token j
+func (t *esModuleTransformer) skipImportAttributes(j int) int {
+	if next := t.token(j + 1); next != nil && !next.newlineBefore && (next.is("with") || next.is("assert")) && t.token(j+2).is("{") {
+		return t.matching(j + 2)
+	}
+	return j
+}
+
+func (t *esModuleTransformer) includeSemicolon(end int) int {
+	if end >= len(t.tokens) {
+		return len(t.tokens) - 1
+	}
+	if t.token(end + 1).is(";") {
+		return end + 1
+	}
+	return end
+}
+
+// statementEnd finds the last token of the statement starting at token i, allowing for automatic semicolon insertion
+func (t *esModuleTransformer) statementEnd(i int) int {
+	depth := 0
+	for j := i; j < len(t.tokens); j++ {
+		token := t.tokens[j]
+		switch {
+		case token.isOpening():
+			depth++
+		case token.isClosing():
+			depth--
+			if depth < 0 {
+				return j - 1
+			}
+		case depth == 0 && token.is(";"):
+			return j
+		}
+
+		if next := t.token(j + 1); depth == 0 && next != nil && next.newlineBefore && canEndStatement(token) && !continuesStatement(next) {
+			return j
+		}
+	}
+	return len(t.tokens) - 1
+}
+
+func canEndStatement(token *jsToken) bool {
+	switch token.kind {
+	case jsIdentifier:
+		return !jsKeywordsBeforeExpression[token.text]
+	case jsPunctuation:
+		return token.isClosing()
+	}
+	return true
+}
+
+func continuesStatement(token *jsToken) bool {
+	switch token.kind {
+	case jsTemplate:
+		return true // a tagged template
+	case jsIdentifier:
+		return token.text == "in" || token.text == "instanceof"
+	case jsPunctuation:
+		return strings.Contains(".([,?:=+-*/%<>&|^", token.text)
+	}
+	return false
+}
+
+// bodyEnd finds the closing brace of a function or class declared at token i
+func (t *esModuleTransformer) bodyEnd(i int) int {
+	j := i
+	if t.tokens[i].is("function") || t.tokens[i].is("async") {
+		for j < len(t.tokens) && !t.tokens[j].is("(") {
+			j++
+		}
+		j = t.matching(j) + 1
+	}
+	for depth := 0; j < len(t.tokens); j++ {
+		token := t.tokens[j]
+		if depth == 0 && token.is("{") {
+			return t.matching(j)
+		}
+		if token.isOpening() {
+			depth++
+		} else if token.isClosing() {
+			depth--
+		}
+	}
+	return len(t.tokens) - 1
+}
+
+// matching finds the bracket which closes the one at token open
+func (t *esModuleTransformer) matching(open int) int {
+	depth := 0
+	for j := open; j < len(t.tokens); j++ {
+		if t.tokens[j].isOpening() {
+			depth++
+		} else if t.tokens[j].isClosing() {
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return len(t.tokens) - 1
+}
+
+func (t *esModuleTransformer) addDependency(quoted string) string {
+	dependency := unquoteJS(&jsToken{kind: jsString, text: quoted})
+	if _, found := t.setters[dependency]; !found {
+		t.dependencies = append(t.dependencies, dependency)
+		t.setters[dependency] = nil
+	}
+	return dependency
+}
+
+// resolveLocalExports decides when each exported local binding is exported: hoisted functions before the module
+// executes, imported bindings whenever their dependency changes and anything else once it has been declared, and again
+// whenever it is reassigned
+func (t *esModuleTransformer) resolveLocalExports() {
+	for _, binding := range t.bindings {
+		t.importedFrom[binding] = ""
+	}
+	for dependency, assignments := range t.setters {
+		for _, assignment := range assignments {
+			t.importedFrom[assignment[:strings.Index(assignment, " = ")]] = dependency
+		}
+	}
+
+	exportedAs := map[string][]string{}
+	for _, export := range t.localExports {
+		call := exportCall(export.exported, export.local)
+		if dependency, found := t.importedFrom[export.local]; found {
+			t.setterExports[dependency] = append(t.setterExports[dependency], call)
+			continue
+		}
+
+		declaration := t.declarations[export.local]
+		switch {
+		case declaration == nil:
+			t.endExports = append(t.endExports, call)
+		case declaration.hoisted:
+			t.startExports = append(t.startExports, call)
+		default:
+			t.insert(declaration.insertAt, declaration.insertAfter+call)
+			declaration.insertAfter = " "
+			if declaration.mutable {
+				exportedAs[export.local] = append(exportedAs[export.local], export.exported)
+			}
+		}
+	}
+	t.liveExports(exportedAs)
+}
+
+// jsAssignmentOperators are the operators which assign to a binding, longest first
+var jsAssignmentOperators = []string{">>>=", "**=", "<<=", ">>=", "&&=", "||=", "??=", "+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "="}
+
+// jsControlKeywords are the keywords which may be followed by a parenthesised expression and a block, unlike a function
+var jsControlKeywords = map[string]bool{"if": true, "for": true, "while": true, "switch": true, "with": true, "catch": true}
+
+type tokenRange struct {
+	first int
+	last  int
+}
+
+// liveExports rewrites each assignment to, or update of, an exported var or let binding so that its new value is
+// exported too, as tsc does, e.g. count = 1 becomes exports_1("count", count = 1), ++count becomes
+// exports_1("count", ++count) and count++ becomes (__updated = count++, exports_1("count", count), __updated).
+// exportedAs maps each binding to the names it is exported as
+func (t *esModuleTransformer) liveExports(exportedAs map[string][]string) {
+	if len(exportedAs) == 0 {
+		return
+	}
+	shadowed := t.shadowedExports(exportedAs)
+
+	// backwards, so that an assignment within another is closed first, when both end at the same offset
+	for i := len(t.tokens) - 1; i >= 0; i-- {
+		token := t.tokens[i]
+		names := exportedAs[token.text]
+		if token.kind != jsIdentifier || len(names) == 0 || t.token(i-1).is(".") || t.withinDeclaration(token.text, i) || within(shadowed[token.text], i) {
+			continue
+		}
+
+		opening, closing := "", ""
+		for _, name := range names {
+			opening += "exports_1(" + quoteJS(name) + ", "
+			closing += ")"
+		}
+		switch {
+		case t.operatorAt(i-2, "++") || t.operatorAt(i-2, "--"):
+			t.insert(t.tokens[i-2].start, opening)
+			t.insert(token.end, closing)
+		case (t.operatorAt(i+1, "++") || t.operatorAt(i+1, "--")) && !t.tokens[i+1].newlineBefore:
+			t.hasUpdates = true
+			t.insert(token.start, "("+esModuleUpdatedValue+" = ")
+			t.insert(t.tokens[i+2].end, ", "+opening+token.text+closing+", "+esModuleUpdatedValue+")")
+		default:
+			if operator := t.assignmentOperator(i + 1); operator > 0 {
+				t.insert(token.start, opening)
+				t.insert(t.tokens[t.expressionEnd(i+1+operator)].end, closing)
+			}
+		}
+	}
+}
+
+// withinDeclaration tests whether token i is part of the top-level declaration of a binding
+func (t *esModuleTransformer) withinDeclaration(name string, i int) bool {
+	declaration := t.declarations[name]
+	return declaration != nil && declaration.first <= i && i <= declaration.last
+}
+
+func within(ranges []tokenRange, i int) bool {
+	for _, r := range ranges {
+		if r.first <= i && i <= r.last {
+			return true
+		}
+	}
+	return false
+}
+
+// shadowedExports finds the ranges of tokens within which an exported binding is shadowed by a nested declaration,
+// parameter or class member of the same name, so that assignments to it there aren't exported
+func (t *esModuleTransformer) shadowedExports(exportedAs map[string][]string) map[string][]tokenRange {
+	shadowed := map[string][]tokenRange{}
+	shadow := func(names []string, first int, last int) {
+		for _, name := range names {
+			if len(exportedAs[name]) > 0 {
+				shadowed[name] = append(shadowed[name], tokenRange{first, last})
+			}
+		}
+	}
+
+	var opens []int // the brackets enclosing the current token
+	functionBodies := map[int]bool{}
+	classBodies := map[int]bool{}
+	for i, token := range t.tokens {
+		switch {
+		case token.is("{") && t.token(i-1).is(")"):
+			params := t.opening(i - 1)
+			if keyword := t.token(params - 1); keyword.is("catch") {
+				names, _ := t.patternNames(params)
+				shadow(names, params, t.matching(i))
+			} else if keyword.is("function") || keyword != nil && keyword.kind == jsIdentifier && !jsControlKeywords[keyword.text] {
+				names, _ := t.patternNames(params)
+				shadow(names, params, t.matching(i))
+				functionBodies[i] = true
+			}
+		case i > 0 && t.operatorAt(i, "=>"):
+			first, names := i-1, []string{t.tokens[i-1].text}
+			if t.token(i - 1).is(")") {
+				first = t.opening(i - 1)
+				names, _ = t.patternNames(first)
+			}
+			last := t.expressionEnd(i + 2)
+			if t.token(i + 2).is("{") {
+				last = t.matching(i + 2)
+				functionBodies[i+2] = true
+			}
+			shadow(names, first, last)
+		case token.is("class") && !t.token(i-1).is("."):
+			j := i
+			for j < len(t.tokens) && !t.tokens[j].is("{") {
+				j++
+			}
+			classBodies[j] = true
+		case len(opens) > 0 && (token.is("let") || token.is("const")) && !t.token(i-1).is("."):
+			scope := opens[len(opens)-1]
+			last := t.matching(scope)
+			if t.tokens[scope].is("(") && t.token(scope-1).is("for") {
+				// a for loop's declarations are scoped to its body
+				if last = t.matching(scope) + 1; t.token(last).is("{") {
+					last = t.matching(last)
+				} else {
+					last = t.statementEnd(last)
+				}
+			}
+			shadow(t.declaredNames(i, t.statementEnd(i)), scope, last)
+		case len(opens) > 0 && token.is("var") && !t.token(i-1).is("."):
+			// vars are scoped to the enclosing function, or else to the module, where they aren't shadows
+			for j := len(opens) - 1; j >= 0; j-- {
+				if functionBodies[opens[j]] {
+					shadow(t.declaredNames(i, t.statementEnd(i)), opens[j], t.matching(opens[j]))
+					break
+				}
+			}
+		case len(opens) > 0 && classBodies[opens[len(opens)-1]] && token.kind == jsIdentifier:
+			shadow([]string{token.text}, i, i) // a field or method, e.g. count = 1
+		}
+
+		if token.isOpening() {
+			opens = append(opens, i)
+		} else if token.isClosing() && len(opens) > 0 {
+			opens = opens[:len(opens)-1]
+		}
+	}
+	return shadowed
+}
+
+// operatorAt tests whether an operator, which may span several punctuation tokens, starts at token j
+func (t *esModuleTransformer) operatorAt(j int, operator string) bool {
+	token := t.token(j)
+	return token != nil && token.kind == jsPunctuation && strings.HasPrefix(t.src[token.start:], operator)
+}
+
+// assignmentOperator finds the number of tokens in an assignment operator starting at token j, e.g. = or +=, or 0
+func (t *esModuleTransformer) assignmentOperator(j int) int {
+	if t.operatorAt(j, "==") || t.operatorAt(j, "=>") {
+		return 0
+	}
+	for _, operator := range jsAssignmentOperators {
+		if t.operatorAt(j, operator) {
+			return len(operator)
+		}
+	}
+	return 0
+}
+
+// expressionEnd finds the last token of the expression starting at token j, e.g. the right hand side of an assignment,
+// which ends at a comma, semicolon, unmatched bracket or colon, or a line break where a semicolon would be inserted
+func (t *esModuleTransformer) expressionEnd(j int) int {
+	depth, conditionals := 0, 0
+	for ; j < len(t.tokens); j++ {
+		token := t.tokens[j]
+		switch {
+		case token.isOpening():
+			depth++
+		case token.isClosing():
+			depth--
+			if depth < 0 {
+				return j - 1
+			}
+		case depth == 0 && (token.is(",") || token.is(";")):
+			return j - 1
+		case depth == 0 && token.is("?") && !t.operatorAt(j, "?.") && !t.operatorAt(j, "??") && !t.operatorAt(j-1, "??"):
+			conditionals++
+		case depth == 0 && token.is(":"):
+			if conditionals == 0 {
+				return j - 1
+			}
+			conditionals--
+		}
+
+		if next := t.token(j + 1); depth == 0 && next != nil && next.newlineBefore && canEndStatement(token) &&
+			(!continuesStatement(next) || t.operatorAt(j+1, "++") || t.operatorAt(j+1, "--")) {
+			return j // a ++ or -- on the next line is a prefix, so a semicolon is inserted before it
+		}
+	}
+	return len(t.tokens) - 1
+}
+
+// opening finds the bracket which opens the one at token close
+func (t *esModuleTransformer) opening(close int) int {
+	depth := 0
+	for j := close; j >= 0; j-- {
+		if t.tokens[j].isClosing() {
+			depth++
+		} else if t.tokens[j].isOpening() {
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return 0
+}
+
+func (t *esModuleTransformer) replace(start int, end int, replacement string) {
+	t.edits = append(t.edits, textEdit{start, end, replacement})
+}
+
+func (t *esModuleTransformer) insert(offset int, text string) {
+	t.edits = append(t.edits, textEdit{offset, offset, text})
+}
+
+// blank replaces a span with spaces, keeping any line breaks, so that the surrounding code stays where it was
+func (t *esModuleTransformer) blank(start int, end int) {
+	blanked := []byte(t.src[start:end])
+	for i, c := range blanked {
+		if c != '\n' && c != '\r' {
+			blanked[i] = ' '
+		}
+	}
+	t.replace(start, end, string(blanked))
+}
+
+// output applies the edits, then adds the System.register header to the first line, and the closing lines
+func (t *esModuleTransformer) output(name string) []string {
+	sort.SliceStable(t.edits, func(a, b int) bool { return t.edits[a].start < t.edits[b].start })
+	var sb strings.Builder
+	pos := 0
+	for _, edit := range t.edits {
+		if edit.start < pos {
+			continue // overlaps an earlier edit
+		}
+		sb.WriteString(t.src[pos:edit.start])
+		sb.WriteString(edit.replacement)
+		pos = edit.end
+	}
+	sb.WriteString(t.src[pos:])
+
+	lines := strings.Split(strings.Replace(sb.String(), "\r\n", "\n", -1), "\n")
+	lines[0] = t.header(name) + strings.TrimPrefix(lines[0], byteOrderMark)
+	if len(t.endExports) > 0 {
+		lines = append(lines, "\t\t\t"+strings.Join(t.endExports, " "))
+	}
+	return append(lines, esModuleClosingLines...)
+}
+
+func (t *esModuleTransformer) header(name string) string {
+	var sb strings.Builder
+	sb.WriteString(getRegisterLineForBundle(name, quoteDependencies(t.dependencies)))
+	sb.WriteString(` "use strict"; `)
+	vars := append([]string(nil), t.bindings...)
+	if t.hasUpdates {
+		vars = append(vars, esModuleUpdatedValue)
+	}
+	if len(vars) > 0 {
+		sb.WriteString("var " + strings.Join(vars, ", ") + "; ")
+	}
+	sb.WriteString("return { setters: [")
+	for i, dependency := range t.dependencies {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		statements := append(append([]string(nil), t.setters[dependency]...), t.setterExports[dependency]...)
+		if len(statements) == 0 {
+			sb.WriteString("function () {}")
+			continue
+		}
+		sb.WriteString("function (" + esModuleSetterParam + ") { " + strings.Join(statements, " ") + " }")
+	}
+	sb.WriteString("], execute: ")
+	if t.isAsync {
+		sb.WriteString("async ")
+	}
+	sb.WriteString("function () { ")
+	for _, call := range t.startExports {
+		sb.WriteString(call + " ")
+	}
+	return sb.String()
+}
+
+func exportCall(exported string, value string) string {
+	return fmt.Sprintf("exports_1(%s, %s);", quoteJS(exported), value)
+}
+
+func quoteJS(s string) string {
+	return util.JSONEncodeString(s)
+}
+
+// unquoteJS gets the value of a string token, or the text of any other token
+func unquoteJS(token *jsToken) string {
+	if token.kind != jsString {
+		return token.text
+	}
+	return unquoteJSString(token.text)
+}
@@ -0,0 +1,206 @@
+package source
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseESModuleDependencies(t *testing.T) {
+	cases := map[string]struct {
+		source       string
+		dependencies []string
+		isModule     bool
+	}{
+		"script": {
+			source: "var a = 1;\nfunction f() { return import(\"./lazy\"); }",
+		},
+		"system.register-like text": {
+			source: "// import x from \"./commented\"\nvar s = \"export default 1\";\nvar t = `import ${a} from \"./templated\"`;",
+		},
+		"side effect": {
+			source:       `import "./polyfill";`,
+			dependencies: []string{"./polyfill"},
+			isModule:     true,
+		},
+		"every import form": {
+			source:       "import a from './a'\nimport * as b from \"./b\";\nimport { c, d as e } from './c';\nimport f, { g } from \"./a\";",
+			dependencies: []string{"./a", "./b", "./c"},
+			isModule:     true,
+		},
+		"re-exports": {
+			source:       "export * from './a';\nexport * as b from './b';\nexport { c as d } from \"./c\";",
+			dependencies: []string{"./a", "./b", "./c"},
+			isModule:     true,
+		},
+		"exports only": {
+			source:   "export const a = 1;",
+			isModule: true,
+		},
+		"dynamic imports aren't dependencies": {
+			source:       "import './a';\nexport function load() { return import('./b'); }",
+			dependencies: []string{"./a"},
+			isModule:     true,
+		},
+	}
+
+	for name, c := range cases {
+		dependencies, isModule := ParseESModuleDependencies(c.source)
+		assert.Equal(t, c.isModule, isModule, name)
+		assert.Equal(t, c.dependencies, dependencies, name)
+	}
+}
+
+func TestTransformESModule(t *testing.T) {
+	cases := map[string]struct {
+		source   string
+		header   string
+		expected []string
+	}{
+		"imports": {
+			source: "import a from './a';\nimport * as b from \"./b\";\nimport { c, d as e } from './a';\nconsole.log(a, b, c, e);",
+			header: `System.register("abc.js", ["./a", "./b"], function (exports_1, context_1) { "use strict"; var a, b, c, e; ` +
+				`return { setters: [function (__module) { a = __module["default"]; c = __module["c"]; e = __module["d"]; }, ` +
+				`function (__module) { b = __module; }], execute: function () { `,
+			expected: []string{"                    ", "                         ", "                                ", "console.log(a, b, c, e);"},
+		},
+		"side effect import": {
+			source:   "import './a'\nrun()",
+			header:   `System.register("abc.js", ["./a"], function (exports_1, context_1) { "use strict"; return { setters: [function () {}], execute: function () { `,
+			expected: []string{"            ", "run()"},
+		},
+		"exported declarations": {
+			source: "export const a = 1, { b, c: d } = obj;\nexport let e = 2\nexport class F {}\nexport function g() {}",
+			header: `System.register("abc.js", [], function (exports_1, context_1) { "use strict"; return { setters: [], execute: function () { exports_1("g", g); `,
+			expected: []string{
+				`       const a = 1, { b, c: d } = obj; exports_1("a", a); exports_1("b", b); exports_1("d", d);`,
+				`       let e = 2; exports_1("e", e);`,
+				`       class F {} exports_1("F", F);`,
+				`       function g() {}`,
+			},
+		},
+		"export list": {
+			source: "import { x } from './x';\nconst a = 1;\nfunction b() {}\nexport { a as default, b, x as y };",
+			header: `System.register("abc.js", ["./x"], function (exports_1, context_1) { "use strict"; var x; ` +
+				`return { setters: [function (__module) { x = __module["x"]; exports_1("y", x); }], execute: function () { exports_1("b", b); `,
+			expected: []string{
+				"                        ",
+				`const a = 1; exports_1("default", a);`,
+				"function b() {}",
+				"                                   ",
+			},
+		},
+		"export default expression": {
+			source:   "export default {\n  a: 1\n};",
+			header:   `System.register("abc.js", [], function (exports_1, context_1) { "use strict"; return { setters: [], execute: function () { `,
+			expected: []string{`exports_1("default", {`, "  a: 1", "});"},
+		},
+		"export default anonymous function": {
+			source:   "export default function () {\n}\n",
+			header:   `System.register("abc.js", [], function (exports_1, context_1) { "use strict"; return { setters: [], execute: function () { `,
+			expected: []string{`exports_1("default", function () {`, "});", ""},
+		},
+		"export default named class": {
+			source:   "export default class A {}",
+			header:   `System.register("abc.js", [], function (exports_1, context_1) { "use strict"; return { setters: [], execute: function () { `,
+			expected: []string{`               class A {} exports_1("default", A);`},
+		},
+		"re-exports": {
+			source: "export * from './a';\nexport { b as c } from './b';",
+			header: `System.register("abc.js", ["./a", "./b"], function (exports_1, context_1) { "use strict"; ` +
+				`return { setters: [function (__module) { for (var __name in __module) if (__name !== "default") exports_1(__name, __module[__name]); }, ` +
+				`function (__module) { exports_1("c", __module["b"]); }], execute: function () { `,
+			expected: []string{"                    ", "                             "},
+		},
+		"dynamic import and import.meta": {
+			source:   "export const url = import.meta.url;\nexport function load() { return import('./lazy'); }",
+			header:   `System.register("abc.js", [], function (exports_1, context_1) { "use strict"; return { setters: [], execute: function () { exports_1("load", load); `,
+			expected: []string{`       const url = context_1.meta.url; exports_1("url", url);`, `       function load() { return context_1.import('./lazy'); }`},
+		},
+		"top-level await": {
+			source:   "export const data = await fetch('/data');",
+			header:   `System.register("abc.js", [], function (exports_1, context_1) { "use strict"; return { setters: [], execute: async function () { `,
+			expected: []string{`       const data = await fetch('/data'); exports_1("data", data);`},
+		},
+	}
+
+	for name, c := range cases {
+		module := TransformESModule("abc", c.source)
+		if !assert.NotNil(t, module, name) {
+			continue
+		}
+		expected := append([]string(nil), c.expected...)
+		expected[0] = c.header + expected[0]
+		expected = append(expected, esModuleClosingLines...)
+		assert.Equal(t, expected, module.Lines, name)
+	}
+}
+
+func TestTransformESModuleLiveBindings(t *testing.T) {
+	cases := map[string]struct {
+		source   string
+		expected string
+	}{
+		"reassigned in a function": {
+			source:   "export let count = 0;\nexport function reset() { count = 0; }",
+			expected: `       function reset() { exports_1("count", count = 0); }`,
+		},
+		"compound assignment": {
+			source:   "export let count = 0;\nexport function add(n) { count += n * 2 }",
+			expected: `       function add(n) { exports_1("count", count += n * 2) }`,
+		},
+		"prefix update": {
+			source:   "export var count = 0;\nexport function inc() { return ++count; }",
+			expected: `       function inc() { return exports_1("count", ++count); }`,
+		},
+		"postfix update": {
+			source:   "export let count = 0;\nexport function inc() { count++; }",
+			expected: `       function inc() { (__updated = count++, exports_1("count", count), __updated); }`,
+		},
+		"exported under several names": {
+			source:   "let count = 0;\nfunction reset() { count = 0 }\nexport { count, count as total };",
+			expected: `function reset() { exports_1("count", exports_1("total", count = 0)) }`,
+		},
+		"shadowed by a parameter": {
+			source:   "export let count = 0;\nexport function set(count) { count = 1; }",
+			expected: `       function set(count) { count = 1; }`,
+		},
+		"shadowed by a nested declaration": {
+			source:   "export let count = 0;\nexport function set() { let count; count = 1; }",
+			expected: `       function set() { let count; count = 1; }`,
+		},
+		"comparison and property": {
+			source:   "export let count = 0;\nexport function test(o) { o.count = 1; return count == 1 || count >= 2; }",
+			expected: `       function test(o) { o.count = 1; return count == 1 || count >= 2; }`,
+		},
+		"const": {
+			source:   "export const count = 0;\nexport function get() { return count; }",
+			expected: `       function get() { return count; }`,
+		},
+	}
+
+	for name, c := range cases {
+		module := TransformESModule("abc", c.source)
+		if !assert.NotNil(t, module, name) {
+			continue
+		}
+		assert.Equal(t, c.expected, module.Lines[1], name)
+		assert.Equal(t, strings.Count(c.source, "\n")+1+len(esModuleClosingLines), len(module.Lines), name) // no trailing exports
+	}
+
+	module := TransformESModule("abc", "export let count = 0;\nexport function inc() { count++; }")
+	assert.Contains(t, module.Lines[0], `"use strict"; var __updated; return`)
+	assert.Contains(t, module.Lines[0], `let count = 0; exports_1("count", count);`)
+}
+
+func TestTransformESModulePreservesLines(t *testing.T) {
+	source := "import {\n  a,\n  b\n} from './ab';\n\nexport const c = a + b; // sum\n"
+	module := TransformESModule("abc", source)
+	assert.Equal(t, strings.Count(source, "\n")+1+len(esModuleClosingLines), len(module.Lines))
+	assert.Equal(t, `       const c = a + b; exports_1("c", c); // sum`, module.Lines[5])
+}
+
+func TestTransformESModuleScript(t *testing.T) {
+	assert.Nil(t, TransformESModule("abc", "var a = 1;"))
+}
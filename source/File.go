@@ -1,42 +1,85 @@
 package source
 
 import (
+	"errors"
+	"fmt"
+	"path"
 	"path/filepath"
-	"strings"
+	"github.com/mrcrowl/swarm/cache"
 	"github.com/mrcrowl/swarm/config"
 	"github.com/mrcrowl/swarm/util"
 )
 
 // File represents a single file containing source code
 type File struct {
-	ID        string // also happens to be the root-relative url for this file
-	Filepath  string
-	ext       string
-	contents  FileContents
-	sourceMap *Mapping
+	ID             string // also happens to be the root-relative url for this file
+	Filepath       string
+	ext            string
+	contents       FileContents
+	sourceMap      *Mapping
+	cache          *cache.Cache
+	loaders        *LoaderRegistry
+	sourceRewriter *SourceRewriter
+	loadError      error
+	transforms     *transformResults
+}
+
+// Kinds of value stored in the persistent build cache for a file.  The suffix changes whenever the structure of a value does
+const (
+	registerDependenciesCacheKind = "registerDependencies.5"
+	jsMetadataCacheKind           = "jsMetadata.4"
+	mapPlaybackCacheKind          = "mapPlayback.2"
+)
+
+// registerDependencies is the cached result of parsing the System.register declaration of a file
+type registerDependencies struct {
+	Dependencies []string `json:"dependencies"`
+	Found        bool     `json:"found"`
+	Error        string   `json:"error,omitempty"`
 }
 
 // newFile creates a new SourceFile
 func newFile(id string, absoluteFilepath string) *File {
 	ext := filepath.Ext(absoluteFilepath)
 	return &File{
-		ID:       id,
-		Filepath: absoluteFilepath,
-		ext:      ext,
+		ID:         id,
+		Filepath:   absoluteFilepath,
+		ext:        ext,
+		transforms: newTransformResults(),
 	}
 }
 
 func (file *File) rootRelativeURL() string { return file.ID }
 
-// PathRelativeTo returns a path relative to another path
-func (file *File) PathRelativeTo(runtimeConfig *config.RuntimeConfig, anotherPath string) string {
-	filePath := file.rootRelativeURL()
-	basedAnotherPath, err := filepath.Rel(runtimeConfig.BaseHref, anotherPath)
-	relativeFilepath, err := filepath.Rel(basedAnotherPath, filePath)
+// relativePath gets the root-relative path of the file, including its extension (which a javascript file's ID omits)
+func (file *File) relativePath() string {
+	if path.Ext(file.ID) == file.ext {
+		return file.ID
+	}
+	return file.ID + file.ext
+}
+
+// loaderRegistry gets the registry which chooses the file's loader
+func (file *File) loaderRegistry() *LoaderRegistry {
+	if file.loaders == nil {
+		return defaultLoaderRegistry
+	}
+	return file.loaders
+}
+
+// isCSS gets whether the file is loaded as a stylesheet, which inlines the stylesheets it imports
+func (file *File) isCSS() bool {
+	return file.loaderRegistry().LoaderName(file) == LoaderCSS
+}
+
+// PathRelativeTo returns the path of the file (including its extension) relative to the directory of another
+// root-relative path, e.g. an entry point
+func (file *File) PathRelativeTo(anotherPath string) string {
+	relativeFilepath, err := filepath.Rel(filepath.FromSlash(path.Dir(anotherPath)), filepath.FromSlash(file.relativePath()))
 	if err != nil {
-		return file.rootRelativeURL()
+		return "/" + file.relativePath()
 	}
-	return strings.Replace(relativeFilepath, "\\", "/", -1) + ".ts"
+	return filepath.ToSlash(relativeFilepath)
 }
 
 // Ext gets a file's extension
@@ -64,18 +107,11 @@ func (file *File) LoadContents(runtimeConfig *config.RuntimeConfig) {
 		return
 	}
 
-	var baseHref string
-	if runtimeConfig != nil {
-		baseHref = runtimeConfig.BaseHref
-	}
-
-	switch file.ext {
-	case ".js":
-		file.contents, err = ParseJSFileContents(file.ID, contents)
-	case ".css":
-		file.contents, err = ParseCSSFileContents(file.ID, contents, baseHref)
-	default:
-		file.contents, err = ParseStringFileContents(file.ID, contents)
+	file.contents, err = file.loaderRegistry().Loader(file).Load(file, contents, runtimeConfig)
+	file.loadError = err
+	if err != nil {
+		fmt.Printf("ERROR: %s\n", err)
+		file.contents = &FailedFileContents{}
 	}
 
 	if file.contents == nil {
@@ -83,15 +119,21 @@ func (file *File) LoadContents(runtimeConfig *config.RuntimeConfig) {
 	}
 }
 
+// LoadError gets the error which prevented the file's contents from loading, if any
+func (file *File) LoadError() error {
+	return file.loadError
+}
+
 // UnloadContents clears a file's contents
 func (file *File) UnloadContents() {
 	file.contents = nil
 	file.sourceMap = nil
+	file.loadError = nil
 }
 
-// SourceMap gets a Mapping that wraps the sourceMappingURL found within the file's contents.
-// This only returns true if the file's contents have been loaded
-func (file *File) SourceMap(runtimeConfig *config.RuntimeConfig, entryPointRootRelativePath string) *Mapping {
+// SourceMap gets a Mapping that wraps the sourceMappingURL found within the file's contents, whose source is relative
+// to the bundle of the entry point.  This only returns non-nil if the file's contents have been loaded
+func (file *File) SourceMap(entryPointRootRelativePath string) *Mapping {
 	if file.sourceMap == nil {
 		if file.contents == nil {
 			return nil
@@ -101,13 +143,81 @@ func (file *File) SourceMap(runtimeConfig *config.RuntimeConfig, entryPointRootR
 		if sourceMappingURL == "" {
 			return nil
 		}
-		relativePath := file.PathRelativeTo(runtimeConfig, entryPointRootRelativePath)
+		relativePath := file.PathRelativeTo(entryPointRootRelativePath)
 		absoluteFilepath := filepath.Join(filepath.Dir(file.Filepath), sourceMappingURL)
-		file.sourceMap = NewMapping(sourceMappingURL, relativePath, absoluteFilepath)
+		file.sourceMap = NewMapping(sourceMappingURL, relativePath, path.Dir(entryPointRootRelativePath), absoluteFilepath)
+		file.sourceMap.cache = file.cache
+		file.sourceMap.rewriter = file.sourceRewriter
 	}
 	return file.sourceMap
 }
 
+// RegisterDependencies reads the (unquoted) dependencies from the System.register declaration at the start of the file,
+// from the import and export declarations of an ES module, from the require calls of a CommonJS module, or from the
+// @import statements of a stylesheet, without preparing the rest of its contents.  Returns false if the file is in none
+// of these formats, along with an error if it appears to be System.register, but the declaration is malformed
+func (file *File) RegisterDependencies() ([]string, bool, error) {
+	var cached registerDependencies
+	if !file.cache.Get(file.Filepath, registerDependenciesCacheKind, &cached) {
+		var err error
+		if cached, err = file.readDependencies(); err != nil {
+			return nil, false, err // not cached, since the command may succeed next time
+		}
+		file.cache.Put(file.Filepath, registerDependenciesCacheKind, &cached)
+	}
+
+	if cached.Error != "" {
+		return nil, false, errors.New(cached.Error)
+	}
+	return cached.Dependencies, cached.Found, nil
+}
+
+// registerPrefixSize is how much of a file is read, at first, to find its System.register declaration
+const registerPrefixSize = 4096
+
+// readDependencies parses a file's dependencies for RegisterDependencies.  Since a System.register declaration is at
+// the start of the file, the rest of the file is only read if the declaration isn't found within the first few KB
+func (file *File) readDependencies() (registerDependencies, error) {
+	var deps registerDependencies
+	contents, complete, err := util.ReadPrefix(file.Filepath, registerPrefixSize)
+	if err != nil {
+		return deps, nil
+	}
+
+	transform := file.loaderRegistry().transform(file)
+	if transform == nil && !complete {
+		if decl, err := ParseRegisterDeclaration(file.Filepath, contents); decl != nil && err == nil {
+			deps.Dependencies, deps.Found = decl.Dependencies, true
+			return deps, nil
+		}
+	}
+	if !complete {
+		if contents, err = util.ReadContents(file.Filepath); err != nil {
+			return deps, nil
+		}
+	}
+	if transform != nil {
+		if contents, err = file.transforms.run(transform, file, contents); err != nil {
+			return deps, err
+		}
+	}
+
+	decl, err := ParseRegisterDeclaration(file.Filepath, contents)
+	if err != nil {
+		deps.Error = err.Error()
+	} else if decl != nil {
+		deps.Dependencies, deps.Found = decl.Dependencies, true
+	} else if file.loaderRegistry().LoaderName(file) == LoaderJS {
+		deps.Dependencies, deps.Found = ParseESModuleDependencies(contents)
+		if !deps.Found {
+			deps.Dependencies, deps.Found = ParseCommonJSDependencies(contents)
+		}
+	} else if file.isCSS() {
+		deps.Dependencies, deps.Found = ParseCSSImports(contents), true
+	}
+	return deps, nil
+}
+
 // BundleBody returns a list of lines from the body ready to include in a SystemJSBundle
 func (file *File) BundleBody() []string {
 	return file.contents.BundleLines()
@@ -2,6 +2,7 @@ package source
 
 import (
 	"fmt"
+	"sort"
 )
 
 // FileSet is
@@ -9,6 +10,9 @@ type FileSet struct {
 	index        map[string]*File
 	links        map[string][]string
 	reverseLinks map[string][]string
+	dependencies map[string][]string
+	dependents   map[string][]string
+	missing      map[string]bool
 	workspace    *Workspace
 	dirty        bool
 }
@@ -19,6 +23,9 @@ func NewEmptyFileSet(workspace *Workspace) *FileSet {
 		index:        make(map[string]*File),
 		links:        make(map[string][]string),
 		reverseLinks: make(map[string][]string),
+		dependencies: make(map[string][]string),
+		dependents:   make(map[string][]string),
+		missing:      make(map[string]bool),
 		workspace:    workspace,
 		dirty:        true,
 	}
@@ -38,8 +45,10 @@ func (fs *FileSet) Ingest(imports []*Import, links []*DependencyLink, replace bo
 		file, err := fs.workspace.ReadSourceFile(imp)
 		if err != nil {
 			fmt.Printf("Could not read '%s'\n", imp.Path())
+			fs.missing[imp.Path()] = true
 			continue
 		}
+		delete(fs.missing, imp.Path())
 
 		if replace {
 			fs.Replace(file)
@@ -78,6 +87,27 @@ func (fs *FileSet) Files() []*File {
 	return result
 }
 
+// MissingPaths returns a sorted list of imported paths that could not be read from the workspace
+func (fs *FileSet) MissingPaths() []string {
+	paths := make([]string, 0, len(fs.missing))
+	for path := range fs.missing {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// LoadErrors returns the errors which prevented files in the set from loading, sorted by file
+func (fs *FileSet) LoadErrors() []error {
+	var errs []error
+	for _, id := range fs.sortedFileIDs() {
+		if err := fs.index[id].LoadError(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
 // Get gets a File from the FileSet
 func (fs *FileSet) Get(id string) *File /* may be nil */ {
 	file := fs.index[id]
@@ -112,6 +142,8 @@ func (fs *FileSet) AddLink(link *DependencyLink) bool {
 		return false
 	}
 
+	fs.addDependencies(link)
+
 	for _, dependencyID := range link.dependencyIDs {
 		if !fs.Contains(dependencyID) {
 			// Commented out this warning for now, because builds
@@ -144,6 +176,87 @@ func (fs *FileSet) AddLink(link *DependencyLink) bool {
 	return true
 }
 
+// addDependencies records every dependency of a link, including those outside of this FileSet, which AddLink ignores
+func (fs *FileSet) addDependencies(link *DependencyLink) {
+	for _, dependencyID := range fs.dependencies[link.id] {
+		dependents := fs.dependents[dependencyID]
+		for i, dependentID := range dependents {
+			if dependentID == link.id {
+				fs.dependents[dependencyID] = append(dependents[:i:i], dependents[i+1:]...)
+				break
+			}
+		}
+	}
+
+	fs.dependencies[link.id] = link.dependencyIDs
+	for _, dependencyID := range link.dependencyIDs {
+		fs.dependents[dependencyID] = append(fs.dependents[dependencyID], link.id)
+	}
+}
+
+// DependencyIDs gets the IDs of every file imported by a file, including those outside of this FileSet
+func (fs *FileSet) DependencyIDs(id string) []string {
+	return fs.dependencies[id]
+}
+
+// DependentIDs gets the IDs of files within this FileSet that import a file
+func (fs *FileSet) DependentIDs(id string) []string {
+	return fs.dependents[id]
+}
+
+// CSSImporters gets the stylesheets within this FileSet that inline a file through @import, either directly or through
+// other imported stylesheets, sorted by ID
+func (fs *FileSet) CSSImporters(id string) []*File {
+	seen := map[string]bool{id: true}
+	queue := []string{id}
+	var importerIDs []string
+	for len(queue) > 0 {
+		for _, dependentID := range fs.reverseLinks[queue[0]] {
+			if dependent := fs.index[dependentID]; !seen[dependentID] && dependent != nil && dependent.isCSS() {
+				seen[dependentID] = true
+				queue = append(queue, dependentID)
+				importerIDs = append(importerIDs, dependentID)
+			}
+		}
+		queue = queue[1:]
+	}
+
+	sort.Strings(importerIDs)
+	importers := make([]*File, len(importerIDs))
+	for i, importerID := range importerIDs {
+		importers[i] = fs.index[importerID]
+	}
+	return importers
+}
+
+// InjectedStylesheets gets the stylesheets that must be injected again when a stylesheet changes: the stylesheet itself,
+// unless it is only ever inlined by the @import of other stylesheets, along with each stylesheet that inlines it
+func (fs *FileSet) InjectedStylesheets(id string) []*File {
+	file := fs.Get(id)
+	if file == nil {
+		return nil
+	}
+
+	var injected []*File
+	for _, stylesheet := range append([]*File{file}, fs.CSSImporters(id)...) {
+		if !fs.onlyInlined(stylesheet.ID) {
+			injected = append(injected, stylesheet)
+		}
+	}
+	return injected
+}
+
+// onlyInlined gets whether every file that depends on a file within this FileSet is a stylesheet, which inlines it
+func (fs *FileSet) onlyInlined(id string) bool {
+	dependentIDs := fs.reverseLinks[id]
+	for _, dependentID := range dependentIDs {
+		if dependent := fs.index[dependentID]; dependent == nil || !dependent.isCSS() {
+			return false
+		}
+	}
+	return len(dependentIDs) > 0
+}
+
 // contains tests whether a FileSet contains a file
 func (fs *FileSet) containsFile(file *File) bool {
 	return fs.Contains(file.ID)
@@ -171,24 +284,36 @@ func (fs *FileSet) nonEmpty() bool {
 	return fs.Count() > 0
 }
 
-// func (fs *FileSet) calcBundleOrder() []string {
-// 	graph := NewIDGraph(fs.links)
-// 	topoSortedIDs := graph.SortTopologically(fs.sortedFileIDs())
-// 	// if len(leftOverIDs) > 0 {
-// 	// 	graph.analyseLeftoverIDs(leftOverIDs)
-// 	// }
+// FilesInDependencyOrder returns a list of all Files in the set, where each File appears after its dependencies.
+// Cycles are broken in a stable place, so the order is the same each time.
+func (fs *FileSet) FilesInDependencyOrder() []*File {
+	ids := fs.calcBundleOrder()
+	files := make([]*File, len(ids))
+	for i, id := range ids {
+		files[i] = fs.index[id]
+	}
+	return files
+}
 
-// 	return topoSortedIDs
-// }
+func (fs *FileSet) calcBundleOrder() []string {
+	graph := NewIDGraph(fs.links)
+	topoSortedIDs := graph.SortTopologically(fs.sortedFileIDs())
+	return topoSortedIDs
+}
 
-// func (fs *FileSet) sortedFileIDs() []string {
-// 	ids := make([]string, len(fs.index))
-// 	i := 0
-// 	for id := range fs.index {
-// 		ids[i] = id
-// 		i++
-// 	}
+// Cycles reports the import cycles between files in the FileSet
+func (fs *FileSet) Cycles() *CycleReport {
+	return AnalyseCycles(fs.links, fs.sortedFileIDs())
+}
 
-// 	sort.StringSlice(ids).Sort()
-// 	return ids
-// }
+func (fs *FileSet) sortedFileIDs() []string {
+	ids := make([]string, len(fs.index))
+	i := 0
+	for id := range fs.index {
+		ids[i] = id
+		i++
+	}
+
+	sort.StringSlice(ids).Sort()
+	return ids
+}
@@ -3,6 +3,8 @@ package source
 import (
 	"testing"
 
+	"github.com/mrcrowl/swarm/testutil"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -120,3 +122,70 @@ func indexOf(element string, data []string) int {
 	}
 	return -1
 }
+
+func TestMissingPaths(t *testing.T) {
+	temppath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(temppath)
+	testutil.WriteTextFile(temppath, "present.js", "")
+
+	sut := NewEmptyFileSet(NewWorkspace(temppath))
+	sut.Ingest([]*Import{NewImport("present"), NewImport("absent/b"), NewImport("absent/a")}, nil, false)
+	assert.Equal(t, 1, sut.Count())
+	assert.Equal(t, []string{"absent/a", "absent/b"}, sut.MissingPaths())
+
+	testutil.WriteTextFile(temppath, "absent.js", "")
+	sut.Ingest([]*Import{NewImport("absent")}, nil, true)
+	assert.Equal(t, []string{"absent/a", "absent/b"}, sut.MissingPaths())
+}
+
+func TestAddLinkOutsideFileSet(t *testing.T) {
+	sut := NewEmptyFileSet(createWorkspace())
+	sut.Add(newFile("abcd", "c:\\abcd"))
+	sut.Add(newFile("efgh", "c:\\efgh"))
+
+	success := sut.AddLink(NewDependencyLink("abcd", []string{"efgh", "other/module"}))
+	assert.False(t, success)
+	assert.Equal(t, []string{"efgh", "other/module"}, sut.DependencyIDs("abcd"))
+	assert.Equal(t, []string{"abcd"}, sut.DependentIDs("efgh"))
+	assert.Equal(t, []string{"abcd"}, sut.DependentIDs("other/module"))
+	assert.Equal(t, 0, sut.linkCount()) // a link to another build is dropped, as before
+
+	success = sut.AddLink(NewDependencyLink("abcd", []string{"efgh"}))
+	assert.True(t, success)
+	assert.Equal(t, []string{"efgh"}, sut.DependencyIDs("abcd"))
+	assert.Equal(t, []string{"abcd"}, sut.DependentIDs("efgh"))
+	assert.Empty(t, sut.DependentIDs("other/module"))
+	assert.Equal(t, 1, sut.linkCount())
+}
+
+func TestFilesInDependencyOrder(t *testing.T) {
+	temppath := testutil.CreateTempDir()
+	defer testutil.RemoveTempDir(temppath)
+	for _, name := range []string{"a.js", "b.js", "c.js", "d.js"} {
+		testutil.WriteTextFile(temppath, name, "")
+	}
+
+	imports := []*Import{NewImport("a"), NewImport("b"), NewImport("c"), NewImport("d")}
+	links := []*DependencyLink{
+		NewDependencyLink("a", []string{"c"}),
+		NewDependencyLink("c", []string{"d", "b"}),
+		NewDependencyLink("d", []string{"c"}), // cycle
+	}
+	sut := NewFileSet(imports, links, NewWorkspace(temppath))
+
+	order := func() []string {
+		var ids []string
+		for _, file := range sut.FilesInDependencyOrder() {
+			ids = append(ids, file.ID)
+		}
+		return ids
+	}
+
+	first := order()
+	assert.Len(t, first, 4)
+	assert.True(t, indexOf("b", first) < indexOf("c", first))
+	assert.True(t, indexOf("c", first) < indexOf("a", first))
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, first, order())
+	}
+}
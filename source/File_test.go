@@ -1,8 +1,12 @@
 package source
 
 import (
+	"fmt"
+	"path/filepath"
+	"github.com/mrcrowl/swarm/cache"
 	"github.com/mrcrowl/swarm/config"
 	"github.com/mrcrowl/swarm/testutil"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -129,9 +133,143 @@ func TestSourceMap(t *testing.T) {
 			setup()
 			f := getSampleFile(tc.id, tc.ext, tc.contents)
 			f.EnsureLoaded(nil)
-			has := f.SourceMap(config.NewRuntimeConfig("", ""), ".")
+			has := f.SourceMap(".")
 			assert.Equal(t, tc.expected, has != nil)
 			teardown()
 		})
 	}
 }
+
+func TestPathRelativeTo(t *testing.T) {
+	cases := map[string]struct {
+		id       string
+		ext      string
+		expected string
+	}{
+		"same directory": {"app/src/ep/Util", ".js", "Util.js"},
+		"other module":   {"app/src/lib/Lib", ".js", "../lib/Lib.js"},
+		"stylesheet":     {"app/src/ep/App.css", ".css", "App.css"},
+	}
+	for name, tc := range cases {
+		file := newFile(tc.id, "blah"+tc.ext)
+		assert.Equal(t, tc.expected, file.PathRelativeTo("app/src/ep/App"), name)
+	}
+}
+
+func TestLoadContentsWithCache(t *testing.T) {
+	setup()
+	defer teardown()
+	contents := "System.register([\"./a\", \"./b\"], function (exports_1, context_1) {\n\talert(\"hi\");\n});\n//# sourceMappingURL=blah.js.map"
+	buildCache := cache.Open(filepath.Join(temppath, ".swarm-cache"), "1.0.0")
+
+	uncached := getSampleFile("abcd", ".js", contents)
+	uncached.cache = buildCache
+	dependencies, found, err := uncached.RegisterDependencies()
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []string{"./a", "./b"}, dependencies)
+	uncached.EnsureLoaded(nil)
+	assert.Nil(t, buildCache.Save())
+
+	reopened := cache.Open(filepath.Join(temppath, ".swarm-cache"), "1.0.0")
+	var metadata *jsFileMetadata
+	assert.True(t, reopened.Get(uncached.Filepath, jsMetadataCacheKind, &metadata))
+	var cachedDependencies registerDependencies
+	assert.True(t, reopened.Get(uncached.Filepath, registerDependenciesCacheKind, &cachedDependencies))
+
+	cached := newFile("abcd", uncached.Filepath)
+	cached.cache = reopened
+	dependencies, found, err = cached.RegisterDependencies()
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []string{"./a", "./b"}, dependencies)
+	cached.EnsureLoaded(nil)
+	assert.Equal(t, uncached.BundleBody(), cached.BundleBody())
+	assert.Equal(t, "blah.js.map", cached.contents.SourceMappingURL())
+}
+
+func TestRegisterDependenciesMalformed(t *testing.T) {
+	setup()
+	defer teardown()
+	f := getSampleFile("abcd", ".js", "// header\nSystem.register([\"./a\",\n  ./b], function (exports_1, context_1) {\n});")
+
+	dependencies, found, err := f.RegisterDependencies()
+	assert.False(t, found)
+	assert.Nil(t, dependencies)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), f.Filepath+":3:")
+	}
+}
+
+func TestRegisterDependenciesBeyondPrefix(t *testing.T) {
+	var manyDependencies []string
+	for i := 0; i < 500; i++ {
+		manyDependencies = append(manyDependencies, fmt.Sprintf("./dependency%d", i))
+	}
+	body := "\n" + strings.Repeat("    exports_1(\"a\", 1);\n", 1000) + "});"
+
+	cases := map[string]struct {
+		source   string
+		expected []string
+	}{
+		"declaration within the prefix": {
+			source:   `System.register(["./a", "./b"], function (exports_1, context_1) {` + body,
+			expected: []string{"./a", "./b"},
+		},
+		"declaration straddling the prefix": {
+			source:   `System.register(["` + strings.Join(manyDependencies, `", "`) + `"], function (exports_1, context_1) {` + body,
+			expected: manyDependencies,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			setup()
+			defer teardown()
+			f := getSampleFile("abcd", ".js", tc.source)
+
+			dependencies, found, err := f.RegisterDependencies()
+			assert.Nil(t, err)
+			assert.True(t, found)
+			assert.Equal(t, tc.expected, dependencies)
+		})
+	}
+}
+
+func TestLoadESModule(t *testing.T) {
+	setup()
+	defer teardown()
+	f := getSampleFile("abcd", ".js", "import { b } from \"./b\";\nexport const a = b + 1;\n//# sourceMappingURL=abcd.js.map")
+
+	dependencies, found, err := f.RegisterDependencies()
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []string{"./b"}, dependencies)
+
+	f.EnsureLoaded(nil)
+	body := f.BundleBody()
+	assert.Contains(t, body[0], `System.register("abcd.js", ["./b"], function (exports_1, context_1) {`)
+	assert.Contains(t, body[1], `const a = b + 1; exports_1("a", a);`)
+	assert.Equal(t, "abcd.js.map", f.contents.SourceMappingURL())
+}
+
+func TestLoadCommonJS(t *testing.T) {
+	setup()
+	defer teardown()
+	f := getSampleFile("abcd", ".js", "var b = require(\"./b\");\nmodule.exports = { a: b + 1 };\n//# sourceMappingURL=abcd.js.map")
+
+	dependencies, found, err := f.RegisterDependencies()
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []string{"./b"}, dependencies)
+
+	f.EnsureLoaded(nil)
+	body := f.BundleBody()
+	assert.Len(t, body, 2+len(commonJSClosingLines))
+	assert.Contains(t, body[0], `System.register("abcd.js", ["./b"], function (exports_1, context_1) {`)
+	assert.Contains(t, body[0], `__dependencies["./b"] = __module;`)
+	assert.Contains(t, body[0], `return dependency && "__useDefault" in dependency ? dependency["default"] : dependency;`)
+	assert.True(t, strings.HasSuffix(body[0], `(function (require, exports, module) { var b = require("./b");`))
+	assert.Equal(t, "module.exports = { a: b + 1 };", body[1])
+	assert.Contains(t, body, "\t\t\texports_1(\"__useDefault\", module.exports);")
+	assert.Equal(t, "abcd.js.map", f.contents.SourceMappingURL())
+}
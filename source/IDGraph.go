@@ -1,14 +1,28 @@
 package source
 
 import (
-	"fmt"
+	"sort"
 	"strings"
 )
 
+// maxCycles limits the number of cycles reported, as the number of cycles can grow exponentially
+const maxCycles = 1000
+
 // IDGraph is used for sorting topologically
 type IDGraph struct {
 	egressEdges  map[string][]string
 	ingressEdges map[string][]string
+	brokenEdges  []Edge
+}
+
+// Edge is a directed edge from one ID to another
+type Edge struct {
+	From string
+	To   string
+}
+
+func (edge Edge) String() string {
+	return edge.From + " → " + edge.To
 }
 
 // NewIDGraph creates a new IDGraph
@@ -24,14 +38,26 @@ func NewIDGraph(links map[string][]string) *IDGraph {
 		}
 	}
 
-	for id, dependencyIDs := range links {
-		for _, did := range dependencyIDs {
+	// visit the links in a stable order, so that cycles are always broken in the same place
+	linkIDs := make([]string, 0, len(links))
+	for id := range links {
+		linkIDs = append(linkIDs, id)
+	}
+	sort.Strings(linkIDs)
+
+	for _, id := range linkIDs {
+		for _, did := range links[id] {
 			add(egressEdges, id, did)
 			add(ingressEdges, did, id)
 		}
 	}
 
-	return &IDGraph{egressEdges, ingressEdges}
+	return &IDGraph{egressEdges, ingressEdges, nil}
+}
+
+// BrokenEdges gets the edges that were removed to break cycles during SortTopologically
+func (graph *IDGraph) BrokenEdges() []Edge {
+	return graph.brokenEdges
 }
 
 // SortTopologically sorts the IDs in topographical order, using the links provided to NewIDGraph
@@ -119,6 +145,7 @@ func (graph *IDGraph) breakCycle(id string) {
 		for _, depID := range dependencyIDs {
 			if visited[depID] {
 				// log.Printf("Breaking cycle: %s --> %s", idcurr, depID)
+				graph.brokenEdges = append(graph.brokenEdges, Edge{idcurr, depID})
 				graph.removeDependentID(idcurr, depID)
 				return false
 			}
@@ -137,60 +164,203 @@ func (graph *IDGraph) breakCycle(id string) {
 	return
 }
 
-func (graph *IDGraph) analyseLeftoverIDs(ids []string) {
-	for _, id := range ids {
-		graph.analyseForCycles(id)
+// FindCycles lists every cycle amongst the IDs, as a path which starts and ends with the same ID, up to maxCycles in all
+func (graph *IDGraph) FindCycles(ids []string) [][]string {
+	var cycles [][]string
+	for _, component := range graph.stronglyConnectedComponents(ids) {
+		for _, cycle := range graph.analyseLeftoverIDs(component, maxCycles-len(cycles)) {
+			cycles = append(cycles, cycle)
+		}
+		if len(cycles) >= maxCycles {
+			break
+		}
+	}
+	return cycles
+}
+
+// analyseLeftoverIDs finds up to limit cycles within a group of (strongly connected) IDs
+func (graph *IDGraph) analyseLeftoverIDs(ids []string, limit int) []cyclePath {
+	sortedIDs := append([]string(nil), ids...)
+	sort.Strings(sortedIDs)
+	within := makeHashset(sortedIDs)
+
+	var cycles []cyclePath
+	for _, id := range sortedIDs {
+		cycles = append(cycles, graph.analyseForCycles(id, within, limit-len(cycles))...)
+		if len(cycles) >= limit {
+			break
+		}
+		within.remove(id)
 	}
+	return cycles
 }
 
-func (graph *IDGraph) analyseForCycles(id string) {
+// analyseForCycles finds up to limit cycles which begin and end at an ID, only visiting IDs within a set.  It uses
+// Johnson's algorithm: an ID is blocked once on the path, and stays blocked until a cycle is found through it, or
+// through an ID it leads to, so that paths which can't return to the start aren't explored again and again
+func (graph *IDGraph) analyseForCycles(id string, within stringHashset, limit int) []cyclePath {
 	cycles := make([]cyclePath, 0, 32)
 	path := make(cyclePath, 0, 32)
-	var recurse func(string)
-	recurse = func(idcurr string) {
+	blocked := make(stringHashset)
+	blockedBy := make(map[string]stringHashset) // the IDs to unblock when an ID is unblocked
+
+	var unblock func(string)
+	unblock = func(idcurr string) {
+		delete(blocked, idcurr)
+		for blockedID := range blockedBy[idcurr] {
+			delete(blockedBy[idcurr], blockedID)
+			if blocked[blockedID] {
+				unblock(blockedID)
+			}
+		}
+	}
+
+	var recurse func(string) bool
+	recurse = func(idcurr string) bool {
+		foundCycle := false
 		path = append(path, idcurr)
-		dependencyIDs := graph.egressEdges[idcurr]
+		blocked[idcurr] = true
+		dependencyIDs := append([]string(nil), graph.egressEdges[idcurr]...)
+		sort.Strings(dependencyIDs)
 
 		for _, depID := range dependencyIDs {
-			seenIndex := path.seenIndex(depID)
-			if seenIndex >= 0 {
-				path = append(path, depID)
-				pathCopy := append(cyclePath(nil), path[seenIndex:]...)
-				cycles = append(cycles, pathCopy)
-				path = path[:len(path)-1]
+			if len(cycles) >= limit {
+				break
+			}
+			if depID == id {
+				pathCopy := append(cyclePath(nil), path...)
+				cycles = append(cycles, append(pathCopy, depID))
+				foundCycle = true
 				continue
 			}
-			recurse(depID)
+			if within[depID] && !blocked[depID] && recurse(depID) {
+				foundCycle = true
+			}
+		}
+
+		if foundCycle {
+			unblock(idcurr)
+		} else {
+			for _, depID := range dependencyIDs {
+				if within[depID] {
+					if blockedBy[depID] == nil {
+						blockedBy[depID] = make(stringHashset)
+					}
+					blockedBy[depID][idcurr] = true
+				}
+			}
 		}
 		path = path[:len(path)-1]
+		return foundCycle
 	}
 
 	recurse(id)
+	return cycles
+}
+
+// stronglyConnectedComponents groups the IDs which can reach one another (Tarjan's algorithm),
+// ignoring any IDs that aren't part of a cycle
+func (graph *IDGraph) stronglyConnectedComponents(ids []string) [][]string {
+	sortedIDs := append([]string(nil), ids...)
+	sort.Strings(sortedIDs)
+	within := makeHashset(sortedIDs)
+
+	index := 0
+	indices := make(map[string]int)
+	lowLinks := make(map[string]int)
+	onStack := make(map[string]bool)
+	stack := make([]string, 0, 256)
+	var components [][]string
+
+	var connect func(string)
+	connect = func(id string) {
+		indices[id] = index
+		lowLinks[id] = index
+		index++
+		stack = append(stack, id)
+		onStack[id] = true
+
+		for _, depID := range graph.egressEdges[id] {
+			if !within[depID] {
+				continue
+			}
+			if _, visited := indices[depID]; !visited {
+				connect(depID)
+				if lowLinks[depID] < lowLinks[id] {
+					lowLinks[id] = lowLinks[depID]
+				}
+			} else if onStack[depID] && indices[depID] < lowLinks[id] {
+				lowLinks[id] = indices[depID]
+			}
+		}
 
-	displayCycle := func(cycle cyclePath) string {
-		return strings.Join(cycle, " --> ")
+		if lowLinks[id] == indices[id] {
+			var component []string
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component = append(component, top)
+				if top == id {
+					break
+				}
+			}
+			if len(component) > 1 || graph.hasEdge(id, id) {
+				sort.Strings(component)
+				components = append(components, component)
+			}
+		}
 	}
 
-	if len(cycles) > 0 {
-		for _, c := range cycles {
-			fmt.Println(displayCycle(c))
+	for _, id := range sortedIDs {
+		if _, visited := indices[id]; !visited {
+			connect(id)
 		}
 	}
+	return components
 }
 
-///////////////
+func (graph *IDGraph) hasEdge(fromID string, toID string) bool {
+	for _, depID := range graph.egressEdges[fromID] {
+		if depID == toID {
+			return true
+		}
+	}
+	return false
+}
 
-type cyclePath []string
+// CycleReport describes the cycles amongst a set of linked IDs, and the edges removed to break them when sorting
+type CycleReport struct {
+	Cycles      [][]string
+	BrokenEdges []Edge
+}
 
-func (path cyclePath) seenIndex(id string) int {
-	for i, seenID := range path {
-		if seenID == id {
-			return i
-		}
+// AnalyseCycles creates a CycleReport for a set of IDs
+func AnalyseCycles(links map[string][]string, ids []string) *CycleReport {
+	cycles := NewIDGraph(links).FindCycles(ids)
+	if len(cycles) == 0 {
+		return &CycleReport{}
 	}
-	return -1
+
+	graph := NewIDGraph(links)
+	graph.SortTopologically(ids)
+	return &CycleReport{cycles, graph.BrokenEdges()}
+}
+
+// Empty tests whether no cycles were found
+func (report *CycleReport) Empty() bool {
+	return len(report.Cycles) == 0
 }
 
+// DisplayCycle formats a cycle as a path, e.g. A → B → C → A
+func DisplayCycle(cycle []string) string {
+	return strings.Join(cycle, " → ")
+}
+
+///////////////
+
+type cyclePath []string
+
 /////////////////
 
 type stringStack struct {
@@ -240,14 +410,15 @@ func (shs stringHashset) nonEmpty() bool {
 	return len(shs) > 0
 }
 
+// first gets the lowest ID in the set
 func (shs stringHashset) first() string {
-	if shs.nonEmpty() {
-		for k := range shs {
-			return k
+	first := ""
+	for k := range shs {
+		if first == "" || k < first {
+			first = k
 		}
 	}
-
-	return ""
+	return first
 }
 
 func (shs stringHashset) removeAll(ids []string) {
@@ -267,5 +438,6 @@ func (shs stringHashset) ids() []string {
 		ids[i] = id
 		i++
 	}
+	sort.Strings(ids)
 	return ids
 }
@@ -1,7 +1,9 @@
 package source
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -24,3 +26,78 @@ func TestStringStack(t *testing.T) {
 	b := ss.pop()
 	assert.Equal(t, "b", b)
 }
+
+func TestFindCycles(t *testing.T) {
+	links := map[string][]string{
+		"a": []string{"b"},
+		"b": []string{"c", "d"},
+		"c": []string{"a"},
+		"d": []string{"d", "e"},
+		"e": []string{"b"},
+		"f": []string{"a"},
+	}
+	g := NewIDGraph(links)
+	cycles := g.FindCycles([]string{"a", "b", "c", "d", "e", "f"})
+	expected := [][]string{
+		{"a", "b", "c", "a"},
+		{"b", "d", "e", "b"},
+		{"d", "d"},
+	}
+	assert.Equal(t, expected, cycles)
+}
+
+func TestFindCyclesLimit(t *testing.T) {
+	// each component is a complete graph, which has more cycles than maxCycles
+	links := map[string][]string{}
+	var ids []string
+	for _, component := range []string{"a", "b"} {
+		var componentIDs []string
+		for i := 0; i < 8; i++ {
+			componentIDs = append(componentIDs, fmt.Sprintf("%s%d", component, i))
+		}
+		for _, id := range componentIDs {
+			links[id] = componentIDs
+		}
+		ids = append(ids, componentIDs...)
+	}
+
+	start := time.Now()
+	cycles := NewIDGraph(links).FindCycles(ids)
+	assert.Len(t, cycles, maxCycles)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestFindCyclesNone(t *testing.T) {
+	links := map[string][]string{
+		"a": []string{"b", "c"},
+		"b": []string{"c"},
+	}
+	g := NewIDGraph(links)
+	assert.Empty(t, g.FindCycles([]string{"a", "b", "c"}))
+}
+
+func TestSortTopologicallyBrokenEdges(t *testing.T) {
+	links := map[string][]string{
+		"a": []string{"b"},
+		"b": []string{"c"},
+		"c": []string{"a"},
+	}
+	g := NewIDGraph(links)
+	topoOrder := g.SortTopologically([]string{"a", "b", "c"})
+	assert.Len(t, topoOrder, 3)
+	assert.Equal(t, []Edge{{"c", "a"}}, g.BrokenEdges())
+	assert.Equal(t, "c → a", g.BrokenEdges()[0].String())
+}
+
+func TestAnalyseCycles(t *testing.T) {
+	links := map[string][]string{
+		"x": []string{"y"},
+		"y": []string{"x"},
+	}
+	report := AnalyseCycles(links, []string{"x", "y"})
+	assert.False(t, report.Empty())
+	assert.Equal(t, "x → y → x", DisplayCycle(report.Cycles[0]))
+	assert.Equal(t, []Edge{{"y", "x"}}, report.BrokenEdges)
+
+	assert.True(t, AnalyseCycles(map[string][]string{}, []string{"x"}).Empty())
+}
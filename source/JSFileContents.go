@@ -13,6 +13,7 @@ type JSFileContents struct {
 	sourceMappingURL string
 	lineCount        int
 	isSystemJS       bool
+	metadata         *jsFileMetadata
 }
 
 // BundleLines returns a list of lines from the body ready to include in a SystemJSBundle
@@ -25,41 +26,99 @@ func (jsfc *JSFileContents) SourceMappingURL() string {
 	return jsfc.sourceMappingURL
 }
 
+// jsFileMetadata describes where the parts of a JS file are found, and is kept in the persistent build cache
+type jsFileMetadata struct {
+	NumPreambleLines  int      `json:"numPreambleLines"`
+	Imports           []string `json:"imports"`
+	FoundRegister     bool     `json:"foundRegister"`
+	RegisterEndLine   int      `json:"registerEndLine"`
+	RegisterEndColumn int      `json:"registerEndColumn"`
+	DirectiveLines    []int    `json:"directiveLines"`
+	SourceMappingURL  string   `json:"sourceMappingURL"`
+	FoundSourceMap    bool     `json:"foundSourceMap"`
+	ESModule          bool     `json:"esModule"`
+	CommonJS          bool     `json:"commonJS"`
+}
+
+// readJSFileMetadata finds the preamble, System.register declaration and sourceMappingURL of a JS file
+func readJSFileMetadata(lines []string) *jsFileMetadata {
+	numLines := len(lines)
+	metadata := &jsFileMetadata{}
+	if numLines > 0 {
+		_, metadata.NumPreambleLines = skipPreamble(lines)
+		javascript := strings.Join(lines, "\n")
+		decl, err := ParseRegisterDeclaration("", javascript)
+		if decl != nil && err == nil {
+			metadata.NumPreambleLines = decl.StartLine
+			metadata.Imports = quoteDependencies(decl.Dependencies)
+			metadata.FoundRegister = true
+			metadata.RegisterEndLine = decl.EndLine
+			metadata.RegisterEndColumn = decl.EndColumn
+			metadata.DirectiveLines = decl.DirectiveLines
+		} else if dependencies, isModule := ParseESModuleDependencies(javascript); err == nil && isModule {
+			metadata.NumPreambleLines = 0
+			metadata.Imports = quoteDependencies(dependencies)
+			metadata.ESModule = true
+		} else if dependencies, isCommonJS := ParseCommonJSDependencies(javascript); err == nil && isCommonJS {
+			metadata.NumPreambleLines = 0
+			metadata.Imports = quoteDependencies(dependencies)
+			metadata.CommonJS = true
+		}
+
+		if metadata.NumPreambleLines < numLines {
+			sourceMapLine := lines[numLines-1]
+			metadata.SourceMappingURL, metadata.FoundSourceMap = parseSourceMappingURL(sourceMapLine)
+		}
+	}
+	return metadata
+}
+
 // ParseJSFileContents parses the contents of a JS file
 func ParseJSFileContents(name string, fileContents string) (*JSFileContents, error) {
-	lines := util.StringToLines(fileContents)
+	return parseJSFileContents(name, util.StringToLines(fileContents), nil)
+}
+
+// parseJSFileContents parses the lines of a JS file, using previously read metadata if available
+func parseJSFileContents(name string, lines []string, metadata *jsFileMetadata) (*JSFileContents, error) {
+	if metadata == nil {
+		metadata = readJSFileMetadata(lines)
+	}
 
 	numLines := len(lines)
+	if metadata.ESModule && numLines > 0 {
+		if contents := parseESModuleContents(name, lines, metadata); contents != nil {
+			return contents, nil
+		}
+	}
+
 	var imports []string
 	var foundRegister = false
 	var sourceMappingURL = ""
-	var foundSourceMap = false
 	var body []string
 	var preamble []string
-	var numPreambleLines int
 	if numLines > 0 {
-		preamble, numPreambleLines = skipPreamble(lines)
-		if numPreambleLines == numLines {
+		preamble = lines[:metadata.NumPreambleLines]
+		if metadata.NumPreambleLines == numLines {
 			body = preamble
 			preamble = []string{}
-			sourceMappingURL = ""
 		} else {
-			registerLine := lines[numPreambleLines]
-			imports, foundRegister = ParseRegisterDependencies(registerLine, false)
-
-			sourceMapLine := lines[numLines-1]
-			sourceMappingURL, foundSourceMap = parseSourceMappingURL(sourceMapLine)
-
-			body = chooseBodyLines(lines, numPreambleLines, foundSourceMap)
+			imports, foundRegister = metadata.Imports, metadata.FoundRegister
+			sourceMappingURL = metadata.SourceMappingURL
+			body = chooseBodyLines(lines, metadata.NumPreambleLines, metadata.FoundSourceMap)
 		}
 	}
 
 	bodyCopy := []string(nil)
 	bodyCopy = append(bodyCopy, preamble...)
+	for _, directiveLine := range metadata.DirectiveLines {
+		if directiveLine < len(bodyCopy) {
+			bodyCopy[directiveLine] = "" // blanked, rather than removed, so that source maps still line up
+		}
+	}
 
 	if foundRegister {
 		bodyCopy = append(bodyCopy, body...)
-		bodyCopy[len(preamble)] = getRegisterLineForBundle(name, imports)
+		replaceRegisterDeclaration(bodyCopy, len(preamble), metadata, getRegisterHeaderForBundle(name, imports))
 	} else {
 		bodyCopy = append(bodyCopy, getRegisterLineForBundle(name, nil))
 		bodyCopy = append(bodyCopy, body...)
@@ -74,11 +133,49 @@ func ParseJSFileContents(name string, fileContents string) (*JSFileContents, err
 		sourceMappingURL: sourceMappingURL,
 		lineCount:        numLines,
 		isSystemJS:       foundRegister,
+		metadata:         metadata,
 	}, nil
 }
 
+// parseESModuleContents transforms the lines of an ES module into a System.register module with the same line structure
+func parseESModuleContents(name string, lines []string, metadata *jsFileMetadata) *JSFileContents {
+	body := chooseBodyLines(lines, 0, metadata.FoundSourceMap)
+	module := TransformESModule(name, strings.Join(body, "\n"))
+	if module == nil {
+		return nil
+	}
+	return &JSFileContents{
+		preamble:         []string{},
+		imports:          metadata.Imports,
+		body:             module.Lines,
+		sourceMappingURL: metadata.SourceMappingURL,
+		lineCount:        len(lines) + len(module.Lines) - len(body),
+		isSystemJS:       true,
+		metadata:         metadata,
+	}
+}
+
+// replaceRegisterDeclaration replaces the (possibly multi-line) System.register declaration with a named one.
+// Any lines the original declaration spanned are blanked, so that source maps still line up
+func replaceRegisterDeclaration(lines []string, startIndex int, metadata *jsFileMetadata, header string) {
+	endIndex := metadata.RegisterEndLine
+	remainder := ""
+	if endIndex < len(lines) && metadata.RegisterEndColumn <= len(lines[endIndex]) {
+		remainder = lines[endIndex][metadata.RegisterEndColumn:]
+	}
+	for i := startIndex + 1; i <= endIndex && i < len(lines); i++ {
+		lines[i] = ""
+	}
+	lines[startIndex] = header + remainder
+}
+
+// getRegisterHeaderForBundle outputs the start of a named System.register declaration, up to the dependency array
+func getRegisterHeaderForBundle(name string, imports []string) string {
+	importsJoined := strings.Join(imports, ", ")
+	return "System.register(\"" + name + ".js\", [" + importsJoined + "]"
+}
+
 // getRegisterLineForBundle outputs the System.register line with a name
 func getRegisterLineForBundle(name string, imports []string) string {
-	importsJoined := strings.Join(imports, ", ")
-	return "System.register(\"" + name + ".js\", [" + importsJoined + "], function (exports_1, context_1) {"
+	return getRegisterHeaderForBundle(name, imports) + ", function (exports_1, context_1) {"
 }
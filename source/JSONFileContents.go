@@ -0,0 +1,132 @@
+package source
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"github.com/mrcrowl/swarm/util"
+)
+
+// JSONFileContents describes a JSON file, exported as a parsed object
+type JSONFileContents struct {
+	lines []string
+}
+
+// BundleLines returns a list of lines ready to include in a SystemJSBundle
+func (jfc *JSONFileContents) BundleLines() []string {
+	return jfc.lines
+}
+
+// SourceMappingURL returns ""
+func (jfc *JSONFileContents) SourceMappingURL() string {
+	return ""
+}
+
+// JSONParseError describes a JSON file with invalid syntax
+type JSONParseError struct {
+	Filename string
+	Line     int // one-based
+	Column   int // one-based
+	Message  string
+}
+
+func (err *JSONParseError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: invalid JSON: %s", err.Filename, err.Line, err.Column, err.Message)
+}
+
+const jsonTemplate = `System.register("%s", [], function (_export, _context) {
+	"use strict";
+
+	var __json = %s;
+
+	return {
+		setters: [],
+		execute: function () {
+			_export("__useDefault", __json);
+			_export("default", __json);%s
+		}
+	}
+});`
+
+// ParseJSONFileContents validates a JSON file and prepares it as a module exporting the parsed value as its default
+// export and, if it is an object, each of its top-level keys as a named export
+func ParseJSONFileContents(name string, fileContents string) (*JSONFileContents, error) {
+	keys, err := readJSONTopLevelKeys(fileContents)
+	if err != nil {
+		return nil, jsonParseError(name, fileContents, err)
+	}
+
+	var namedExports strings.Builder
+	for _, key := range keys {
+		if key == "default" || key == "__useDefault" {
+			continue
+		}
+		quotedKey := util.JSONEncodeString(key)
+		namedExports.WriteString(fmt.Sprintf("\n\t\t\t_export(%s, __json[%s]);", quotedKey, quotedKey))
+	}
+
+	body := fmt.Sprintf(jsonTemplate, name, strings.TrimSpace(strings.TrimPrefix(fileContents, byteOrderMark)), namedExports.String())
+	return &JSONFileContents{util.StringToLines(body)}, nil
+}
+
+// readJSONTopLevelKeys validates JSON, and returns the keys of its top-level object, in order, if it is one
+func readJSONTopLevelKeys(contents string) ([]string, error) {
+	data := []byte(strings.TrimPrefix(contents, byteOrderMark))
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	if _, isObject := value.(map[string]interface{}); !isObject {
+		return nil, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if _, err := decoder.Token(); err != nil { // the opening {
+		return nil, err
+	}
+	var keys []string
+	seen := map[string]bool{}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := token.(string)
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+
+		var skipped json.RawMessage
+		if err := decoder.Decode(&skipped); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// jsonParseError locates an error from encoding/json within the original contents
+func jsonParseError(filename string, contents string, err error) error {
+	offset := int64(len(contents))
+	switch typedErr := err.(type) {
+	case *json.SyntaxError:
+		offset = typedErr.Offset
+	case *json.UnmarshalTypeError:
+		offset = typedErr.Offset
+	}
+	if strings.HasPrefix(contents, byteOrderMark) {
+		offset += int64(len(byteOrderMark))
+	}
+	if offset > int64(len(contents)) {
+		offset = int64(len(contents))
+	}
+
+	prefix := contents[:offset]
+	line := strings.Count(prefix, "\n") + 1
+	column := len(prefix) - strings.LastIndex(prefix, "\n") - 1
+	if column < 1 {
+		column = 1
+	}
+	return &JSONParseError{Filename: filename, Line: line, Column: column, Message: err.Error()}
+}
@@ -0,0 +1,60 @@
+package source
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseJSONFileContents(t *testing.T) {
+	contents, err := ParseJSONFileContents("app/settings.json", "{\n\t\"name\": \"swarm\",\n\t\"default\": true,\n\t\"nested\": {\"a\": [1, 2]}\n}\n")
+	assert.Nil(t, err)
+	javascript := strings.Join(contents.BundleLines(), "\n")
+	assert.Contains(t, javascript, `System.register("app/settings.json", [], function (_export, _context) {`)
+	assert.Contains(t, javascript, "var __json = {\n\t\"name\": \"swarm\",")
+	assert.Contains(t, javascript, `_export("default", __json);`)
+	assert.Contains(t, javascript, `_export("name", __json["name"]);`+"\n\t\t\t"+`_export("nested", __json["nested"]);`)
+	assert.NotContains(t, javascript, `_export("default", __json["default"])`)
+}
+
+func TestParseJSONFileContentsNonObject(t *testing.T) {
+	contents, err := ParseJSONFileContents("list.json", `[1, 2, 3]`)
+	assert.Nil(t, err)
+	javascript := strings.Join(contents.BundleLines(), "\n")
+	assert.Contains(t, javascript, "var __json = [1, 2, 3];")
+	assert.Equal(t, 2, strings.Count(javascript, "_export("))
+}
+
+func TestParseJSONFileContentsUseDefault(t *testing.T) {
+	// like other non-javascript modules, a CommonJS module which requires it gets the default export
+	contents, err := ParseJSONFileContents("app/settings.json", `{"__useDefault": false, "a": 1}`)
+	assert.Nil(t, err)
+	javascript := strings.Join(contents.BundleLines(), "\n")
+	assert.Contains(t, javascript, `_export("__useDefault", __json);`+"\n\t\t\t"+`_export("default", __json);`)
+	assert.Equal(t, 1, strings.Count(javascript, `_export("__useDefault"`))
+	assert.Contains(t, javascript, `_export("a", __json["a"]);`)
+}
+
+func TestParseJSONFileContentsErrors(t *testing.T) {
+	cases := map[string]struct {
+		source string
+		line   int
+		column int
+	}{
+		"bad value":         {"{\n  \"a\": 1,\n  \"b\": x\n}", 3, 8},
+		"trailing comma":    {"{\"a\": 1,}", 1, 9},
+		"unterminated":      {"{\n  \"a\": [1, 2", 2, 12},
+		"trailing contents": {"{} {}", 1, 4},
+	}
+
+	for name, c := range cases {
+		_, err := ParseJSONFileContents("bad.json", c.source)
+		if assert.NotNil(t, err, name) {
+			parseErr := err.(*JSONParseError)
+			assert.Equal(t, "bad.json", parseErr.Filename, name)
+			assert.Equal(t, c.line, parseErr.Line, name)
+			assert.Equal(t, c.column, parseErr.Column, name)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package source
+
+// Kinds of token produced by tokenizeJavascript
+const (
+	jsIdentifier = iota // identifiers and keywords
+	jsNumber
+	jsString
+	jsTemplate // an entire template literal, including any substitutions
+	jsRegex
+	jsPunctuation // a single punctuation character
+)
+
+// jsKeywordsBeforeExpression are the keywords after which a '/' begins a regular expression, rather than a division
+var jsKeywordsBeforeExpression = map[string]bool{
+	"return": true, "typeof": true, "instanceof": true, "in": true, "of": true, "new": true, "delete": true,
+	"void": true, "throw": true, "case": true, "do": true, "else": true, "yield": true, "await": true,
+}
+
+type jsToken struct {
+	kind          int
+	text          string
+	start         int // offset of the token within the source
+	end           int // offset just beyond the token
+	newlineBefore bool
+}
+
+func (token *jsToken) is(text string) bool {
+	return token != nil && token.kind != jsString && token.kind != jsTemplate && token.text == text
+}
+
+func (token *jsToken) isOpening() bool {
+	return token != nil && token.kind == jsPunctuation && (token.text == "(" || token.text == "[" || token.text == "{")
+}
+
+func (token *jsToken) isClosing() bool {
+	return token != nil && token.kind == jsPunctuation && (token.text == ")" || token.text == "]" || token.text == "}")
+}
+
+// jsTokenizer splits javascript into tokens, skipping whitespace and comments.  It is tolerant of malformed input,
+// since it is only used to find module syntax, not to validate the javascript
+type jsTokenizer struct {
+	src     string
+	pos     int
+	tokens  []*jsToken
+	newline bool
+}
+
+// tokenizeJavascript splits javascript source into tokens
+func tokenizeJavascript(src string) []*jsToken {
+	tokenizer := &jsTokenizer{src: src}
+	if len(src) >= len(byteOrderMark) && src[:len(byteOrderMark)] == byteOrderMark {
+		tokenizer.pos = len(byteOrderMark)
+	}
+	for tokenizer.next() != nil {
+	}
+	return tokenizer.tokens
+}
+
+func (tz *jsTokenizer) next() *jsToken {
+	tz.skipWhitespaceAndComments()
+	if tz.pos >= len(tz.src) {
+		return nil
+	}
+
+	start, newlineBefore := tz.pos, tz.newline
+	kind := jsPunctuation
+	c := tz.src[tz.pos]
+	switch {
+	case c == '"' || c == '\'':
+		kind = jsString
+		tz.skipString(c)
+	case c == '`':
+		kind = jsTemplate
+		tz.skipTemplate()
+	case c >= '0' && c <= '9' || c == '.' && tz.pos+1 < len(tz.src) && tz.src[tz.pos+1] >= '0' && tz.src[tz.pos+1] <= '9':
+		kind = jsNumber
+		for tz.pos < len(tz.src) && (isJSWordByte(tz.src[tz.pos]) || tz.src[tz.pos] == '.') {
+			tz.pos++
+		}
+	case isJSWordByte(c):
+		kind = jsIdentifier
+		for tz.pos < len(tz.src) && isJSWordByte(tz.src[tz.pos]) {
+			tz.pos++
+		}
+	case c == '/' && tz.regexAllowed():
+		if end, ok := tz.regexEnd(); ok {
+			kind = jsRegex
+			tz.pos = end
+		} else {
+			tz.pos++
+		}
+	default:
+		tz.pos++
+	}
+
+	token := &jsToken{kind: kind, text: tz.src[start:tz.pos], start: start, end: tz.pos, newlineBefore: newlineBefore}
+	tz.tokens = append(tz.tokens, token)
+	tz.newline = false
+	return token
+}
+
+func (tz *jsTokenizer) skipWhitespaceAndComments() {
+	n := len(tz.src)
+	for tz.pos < n {
+		switch c := tz.src[tz.pos]; {
+		case c == '\n':
+			tz.newline = true
+			tz.pos++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\v' || c == '\f':
+			tz.pos++
+		case c == '/' && tz.pos+1 < n && tz.src[tz.pos+1] == '/':
+			for tz.pos < n && tz.src[tz.pos] != '\n' {
+				tz.pos++
+			}
+		case c == '/' && tz.pos+1 < n && tz.src[tz.pos+1] == '*':
+			end := n
+			for i := tz.pos + 2; i+1 < n; i++ {
+				if tz.src[i] == '*' && tz.src[i+1] == '/' {
+					end = i + 2
+					break
+				}
+			}
+			for i := tz.pos; i < end; i++ {
+				if tz.src[i] == '\n' {
+					tz.newline = true
+				}
+			}
+			tz.pos = end
+		default:
+			return
+		}
+	}
+}
+
+func (tz *jsTokenizer) skipString(quote byte) {
+	n := len(tz.src)
+	for tz.pos++; tz.pos < n; tz.pos++ {
+		switch tz.src[tz.pos] {
+		case '\\':
+			tz.pos++
+		case quote:
+			tz.pos++
+			return
+		case '\n':
+			return // unterminated
+		}
+	}
+}
+
+// skipTemplate skips a template literal, tokenizing (and discarding) any substitutions, so that nested braces,
+// strings and templates are handled
+func (tz *jsTokenizer) skipTemplate() {
+	n := len(tz.src)
+	for tz.pos++; tz.pos < n; tz.pos++ {
+		switch tz.src[tz.pos] {
+		case '\\':
+			tz.pos++
+		case '`':
+			tz.pos++
+			return
+		case '$':
+			if tz.pos+1 < n && tz.src[tz.pos+1] == '{' {
+				tz.pos += 2
+				tz.skipSubstitution()
+				tz.pos-- // the loop increments past the closing }
+			}
+		}
+	}
+}
+
+func (tz *jsTokenizer) skipSubstitution() {
+	outer := tz.tokens
+	tz.tokens = nil
+	defer func() { tz.tokens = outer }()
+
+	depth := 0
+	for token := tz.next(); token != nil; token = tz.next() {
+		switch {
+		case token.isOpening():
+			depth++
+		case token.isClosing():
+			if depth == 0 {
+				return
+			}
+			depth--
+		}
+	}
+}
+
+func (tz *jsTokenizer) regexAllowed() bool {
+	if len(tz.tokens) == 0 {
+		return true
+	}
+	last := tz.tokens[len(tz.tokens)-1]
+	switch last.kind {
+	case jsPunctuation:
+		return !last.isClosing()
+	case jsIdentifier:
+		return jsKeywordsBeforeExpression[last.text]
+	}
+	return false
+}
+
+func (tz *jsTokenizer) regexEnd() (int, bool) {
+	n := len(tz.src)
+	inClass := false
+	for i := tz.pos + 1; i < n; i++ {
+		switch c := tz.src[i]; {
+		case c == '\n':
+			return 0, false
+		case c == '\\':
+			i++
+		case c == '[':
+			inClass = true
+		case c == ']':
+			inClass = false
+		case c == '/' && !inClass:
+			i++
+			for i < n && isJSWordByte(tz.src[i]) {
+				i++
+			}
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// isJSWordByte tests whether a byte can be part of an identifier, keyword or number, including unicode escapes
+func isJSWordByte(c byte) bool {
+	return isIdentifierByte(c) || c == '\\'
+}
@@ -0,0 +1,285 @@
+package source
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/util"
+)
+
+// Loader prepares the contents of a file for bundling
+type Loader interface {
+	Load(file *File, contents string, runtimeConfig *config.RuntimeConfig) (FileContents, error)
+}
+
+// LoaderFunc allows an ordinary function to be used as a Loader
+type LoaderFunc func(file *File, contents string, runtimeConfig *config.RuntimeConfig) (FileContents, error)
+
+// Load calls the function
+func (fn LoaderFunc) Load(file *File, contents string, runtimeConfig *config.RuntimeConfig) (FileContents, error) {
+	return fn(file, contents, runtimeConfig)
+}
+
+// Names of the built-in loaders
+const (
+	LoaderJS      = "js"
+	LoaderCSS     = "css"
+	LoaderJSON    = "json"
+	LoaderText    = "text"
+	LoaderDataURI = "dataURI"
+	LoaderRaw     = "raw"
+)
+
+var loaders = map[string]Loader{
+	LoaderJS:      LoaderFunc(loadJavascript),
+	LoaderCSS:     LoaderFunc(loadCSS),
+	LoaderJSON:    LoaderFunc(loadJSON),
+	LoaderText:    LoaderFunc(loadText),
+	LoaderDataURI: LoaderFunc(loadDataURI),
+	LoaderRaw:     LoaderFunc(loadRaw),
+}
+var loadersMutex sync.RWMutex
+
+// RegisterLoader adds a named loader, which can then be chosen for files in the loaders section of swarm.json
+func RegisterLoader(name string, loader Loader) {
+	loadersMutex.Lock()
+	defer loadersMutex.Unlock()
+	loaders[name] = loader
+}
+
+func lookupLoader(name string) (Loader, bool) {
+	loadersMutex.RLock()
+	defer loadersMutex.RUnlock()
+	loader, found := loaders[name]
+	return loader, found
+}
+
+// defaultLoaderRules choose the loaders for files not matched by any configured rule
+var defaultLoaderRules = []*config.LoaderConfig{
+	{Match: ".js", Loader: LoaderJS},
+	{Match: ".css", Loader: LoaderCSS},
+	{Match: ".json", Loader: LoaderJSON},
+}
+
+// LoaderRegistry chooses the loader for each file, by its extension or a glob of its root-relative path, along with
+// any transform its contents are piped through first
+type LoaderRegistry struct {
+	rules      []*loaderRule
+	transforms []*Transform
+}
+
+type loaderRule struct {
+	*filePattern
+	loader string
+}
+
+// filePattern matches files by extension (e.g. ".svg") or by a glob of their root-relative path (e.g. "**/*.scss")
+type filePattern struct {
+	extension string         // set for extension patterns
+	glob      *regexp.Regexp // set for glob patterns
+	pattern   string
+}
+
+func newFilePattern(pattern string) (*filePattern, error) {
+	if strings.HasPrefix(pattern, ".") && !strings.ContainsAny(pattern, "/*?") {
+		return &filePattern{extension: pattern, pattern: pattern}, nil
+	}
+	glob, err := util.CompileGlob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob '%s': %s", pattern, err)
+	}
+	return &filePattern{glob: glob, pattern: pattern}, nil
+}
+
+func (fp *filePattern) matches(file *File) bool {
+	if fp.extension != "" {
+		return fp.extension == file.ext
+	}
+	return fp.glob.MatchString(file.relativePath())
+}
+
+// finalExtension gets the extension of the files matched, if they all share one, e.g. ".md" for "docs/**/*.md"
+func (fp *filePattern) finalExtension() string {
+	if fp.extension != "" {
+		return fp.extension
+	}
+	if extension := path.Ext(fp.pattern); !strings.ContainsAny(extension, "*?") {
+		return extension
+	}
+	return ""
+}
+
+// defaultLoaderRegistry is used for files read from a workspace without loaders of its own
+var defaultLoaderRegistry, _ = NewLoaderRegistry(nil)
+
+// NewLoaderRegistry creates a LoaderRegistry from the loaders section of swarm.json, which takes precedence over the
+// default loaders.  Files not matched by any rule use the text loader
+func NewLoaderRegistry(configs []*config.LoaderConfig) (*LoaderRegistry, error) {
+	registry := &LoaderRegistry{}
+	for _, loaderConfig := range append(append([]*config.LoaderConfig(nil), configs...), defaultLoaderRules...) {
+		if _, found := lookupLoader(loaderConfig.Loader); !found {
+			return nil, fmt.Errorf("unknown loader '%s' for '%s'", loaderConfig.Loader, loaderConfig.Match)
+		}
+		pattern, err := newFilePattern(loaderConfig.Match)
+		if err != nil {
+			return nil, err
+		}
+		registry.rules = append(registry.rules, &loaderRule{pattern, loaderConfig.Loader})
+	}
+	return registry, nil
+}
+
+// AddTransforms adds the transforms section of swarm.json to the registry
+func (registry *LoaderRegistry) AddTransforms(configs []*config.TransformConfig) error {
+	for _, transformConfig := range configs {
+		transform, err := NewTransform(transformConfig)
+		if err != nil {
+			return err
+		}
+		registry.transforms = append(registry.transforms, transform)
+	}
+	return nil
+}
+
+// transform gets the transform for a file, if it has one
+func (registry *LoaderRegistry) transform(file *File) *Transform {
+	for _, transform := range registry.transforms {
+		if transform.pattern.matches(file) {
+			return transform
+		}
+	}
+	return nil
+}
+
+// LoaderName gets the name of the loader for a file (or for the output of its transform)
+func (registry *LoaderRegistry) LoaderName(file *File) string {
+	if transform := registry.transform(file); transform != nil {
+		return transform.output
+	}
+	for _, rule := range registry.rules {
+		if rule.matches(file) {
+			return rule.loader
+		}
+	}
+	return LoaderText
+}
+
+// Loader gets the loader for a file, which runs its transform first, if it has one
+func (registry *LoaderRegistry) Loader(file *File) Loader {
+	loader, _ := lookupLoader(registry.LoaderName(file))
+	if transform := registry.transform(file); transform != nil {
+		return &transformingLoader{transform, loader}
+	}
+	return loader
+}
+
+// Extensions lists the file extensions handled by the registry's rules and transforms, including the final extension
+// of any glob, e.g. ".md" for "docs/**/*.md"
+func (registry *LoaderRegistry) Extensions() []string {
+	var patterns []*filePattern
+	for _, rule := range registry.rules {
+		patterns = append(patterns, rule.filePattern)
+	}
+	for _, transform := range registry.transforms {
+		patterns = append(patterns, transform.pattern)
+	}
+	return uniqueExtensions(patterns, func(*filePattern) bool { return true })
+}
+
+// ScriptExtensions lists the extensions, other than .js, of files which are loaded (or transformed into) javascript,
+// e.g. ".ts" when a transform compiles typescript.  An import without an extension may refer to any of these
+func (registry *LoaderRegistry) ScriptExtensions() []string {
+	var patterns []*filePattern
+	for _, transform := range registry.transforms {
+		if transform.output == LoaderJS {
+			patterns = append(patterns, transform.pattern)
+		}
+	}
+	for _, rule := range registry.rules {
+		if rule.loader == LoaderJS {
+			patterns = append(patterns, rule.filePattern)
+		}
+	}
+	return uniqueExtensions(patterns, func(pattern *filePattern) bool { return pattern.finalExtension() != ".js" })
+}
+
+// String describes the registry's rules and transforms, so that the build cache can be cleared when they change
+func (registry *LoaderRegistry) String() string {
+	var sb strings.Builder
+	for _, transform := range registry.transforms {
+		fmt.Fprintf(&sb, "transform %s: %s -> %s\n", transform.pattern.pattern, transform.command, transform.output)
+	}
+	for _, rule := range registry.rules {
+		fmt.Fprintf(&sb, "loader %s: %s\n", rule.pattern, rule.loader)
+	}
+	return sb.String()
+}
+
+func uniqueExtensions(patterns []*filePattern, include func(*filePattern) bool) []string {
+	seen := map[string]bool{}
+	var extensions []string
+	for _, pattern := range patterns {
+		extension := pattern.finalExtension()
+		if extension != "" && include(pattern) && !seen[extension] {
+			seen[extension] = true
+			extensions = append(extensions, extension)
+		}
+	}
+	sort.Strings(extensions)
+	return extensions
+}
+
+func loadJavascript(file *File, contents string, runtimeConfig *config.RuntimeConfig) (FileContents, error) {
+	lines := util.StringToLines(contents)
+	var metadata *jsFileMetadata
+	if !file.cache.Get(file.Filepath, jsMetadataCacheKind, &metadata) {
+		metadata = readJSFileMetadata(lines)
+		file.cache.Put(file.Filepath, jsMetadataCacheKind, metadata)
+	}
+	if metadata.CommonJS && len(lines) > 0 {
+		return parseCommonJSFileContents(file.ID, lines, metadata), nil
+	}
+	return parseJSFileContents(file.ID, lines, metadata)
+}
+
+func loadCSS(file *File, contents string, runtimeConfig *config.RuntimeConfig) (FileContents, error) {
+	var baseHref string
+	var inlineAssetBytes int
+	if runtimeConfig != nil {
+		baseHref, inlineAssetBytes = runtimeConfig.BaseHref, runtimeConfig.InlineCSSAssetBytes
+	}
+	stylesheet, err := inlineCSSImports(file.ID, file.Filepath, contents)
+	if err != nil {
+		return nil, err
+	}
+	css := inlineCSSAssets(stylesheet.CSS(), file.Filepath, inlineAssetBytes)
+	if runtimeConfig != nil && runtimeConfig.SourceMapsEnabled() {
+		css += "\n" + stylesheet.sourceMapComment()
+	}
+	return ParseCSSFileContents(file.ID, css, baseHref)
+}
+
+func loadJSON(file *File, contents string, runtimeConfig *config.RuntimeConfig) (FileContents, error) {
+	return ParseJSONFileContents(file.ID, contents)
+}
+
+func loadText(file *File, contents string, runtimeConfig *config.RuntimeConfig) (FileContents, error) {
+	return ParseStringFileContents(file.ID, contents)
+}
+
+func loadDataURI(file *File, contents string, runtimeConfig *config.RuntimeConfig) (FileContents, error) {
+	data, err := ioutil.ReadFile(file.Filepath) // re-read, since the contents have had any byte order mark removed
+	if err != nil {
+		return nil, err
+	}
+	return ParseDataURIFileContents(file.ID, data)
+}
+
+func loadRaw(file *File, contents string, runtimeConfig *config.RuntimeConfig) (FileContents, error) {
+	return ParseRawFileContents(contents)
+}
@@ -0,0 +1,74 @@
+package source
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mrcrowl/swarm/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoaderRegistryLoaderName(t *testing.T) {
+	registry, err := NewLoaderRegistry([]*config.LoaderConfig{
+		{Match: ".svg", Loader: LoaderDataURI},
+		{Match: "vendor/**/*.js", Loader: LoaderRaw},
+		{Match: "*.graphql", Loader: LoaderText},
+	})
+	assert.Nil(t, err)
+
+	cases := map[string]struct {
+		id       string
+		filepath string
+		loader   string
+	}{
+		"default javascript": {"app/a", "/root/app/a.js", LoaderJS},
+		"default css":        {"app/a.css", "/root/app/a.css", LoaderCSS},
+		"default json":       {"app/a.json", "/root/app/a.json", LoaderJSON},
+		"fallback":           {"app/a.html", "/root/app/a.html", LoaderText},
+		"extension":          {"app/icon.svg", "/root/app/icon.svg", LoaderDataURI},
+		"glob":               {"vendor/lib/x", "/root/vendor/lib/x.js", LoaderRaw},
+		"filename glob":      {"app/q/query.graphql", "/root/app/q/query.graphql", LoaderText},
+	}
+
+	for name, c := range cases {
+		assert.Equal(t, c.loader, registry.LoaderName(newFile(c.id, c.filepath)), name)
+	}
+	assert.Equal(t, []string{".css", ".graphql", ".js", ".json", ".svg"}, registry.Extensions())
+}
+
+func TestLoaderRegistryUnknownLoader(t *testing.T) {
+	_, err := NewLoaderRegistry([]*config.LoaderConfig{{Match: ".md", Loader: "markdown"}})
+	assert.NotNil(t, err)
+}
+
+func TestRegisterLoader(t *testing.T) {
+	RegisterLoader("upper", LoaderFunc(func(file *File, contents string, runtimeConfig *config.RuntimeConfig) (FileContents, error) {
+		return ParseStringFileContents(file.ID, strings.ToUpper(contents))
+	}))
+	registry, err := NewLoaderRegistry([]*config.LoaderConfig{{Match: ".md", Loader: "upper"}})
+	assert.Nil(t, err)
+
+	setup()
+	defer teardown()
+	f := getSampleFile("blah.md", ".md", "# title")
+	f.loaders = registry
+	f.EnsureLoaded(nil)
+	assert.Contains(t, strings.Join(f.BundleBody(), "\n"), `"# TITLE"`)
+}
+
+func TestDataURIAndRawLoaders(t *testing.T) {
+	registry, _ := NewLoaderRegistry([]*config.LoaderConfig{{Match: ".svg", Loader: LoaderDataURI}, {Match: ".txt", Loader: LoaderRaw}})
+	setup()
+	defer teardown()
+
+	svg := getSampleFile("blah.svg", ".svg", "<svg/>")
+	svg.loaders = registry
+	svg.EnsureLoaded(nil)
+	assert.Contains(t, strings.Join(svg.BundleBody(), "\n"), `"data:image/svg+xml;base64,PHN2Zy8+"`)
+
+	raw := getSampleFile("blah.txt", ".txt", "System.register(\"x.js\", [], function () {});\n//# sourceMappingURL=x.js.map")
+	raw.loaders = registry
+	raw.EnsureLoaded(nil)
+	assert.Equal(t, []string{"System.register(\"x.js\", [], function () {});"}, raw.BundleBody())
+	assert.Equal(t, "x.js.map", raw.RawContents().SourceMappingURL())
+}
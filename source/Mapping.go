@@ -5,26 +5,42 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"path"
+	"path/filepath"
+	"strings"
+	"github.com/mrcrowl/swarm/cache"
 	"github.com/mrcrowl/swarm/util"
 )
 
 // Mapping is
 type Mapping struct {
 	sourceMappingURL string
-	relativePath     string
+	relativePath     string // the path of the source, relative to the bundle
+	generatedPath    string // the path of the file containing the sourceMappingURL, relative to the bundle
+	bundleDir        string // the root-relative directory of the bundle
 	filepath         string
 	config           *MapConfig
 	playback         *MapPlayback
+	cache            *cache.Cache
+	rewriter         *SourceRewriter
+	loadErr          error
 }
 
 // Playback is
 func (mapping *Mapping) Playback() *MapPlayback {
+	if mapping.playback == nil {
+		var playback *MapPlayback
+		if mapping.cache.Get(mapping.filepath, mapPlaybackCacheKind, &playback) {
+			mapping.playback = playback
+		}
+	}
 	return mapping.playback
 }
 
 // CachePlayback stores a playback to avoid it being recalculated
 func (mapping *Mapping) CachePlayback(playback *MapPlayback) {
 	mapping.playback = playback
+	mapping.cache.Put(mapping.filepath, mapPlaybackCacheKind, playback)
 }
 
 // Mappings returns the string of source mappings
@@ -40,6 +56,7 @@ func (mapping *Mapping) Mappings() string {
 type MapPlayback struct {
 	LineCount    int
 	SegmentDelta Segment
+	NameIndex    int // the index of the name of the last segment which has one
 }
 
 // Segment is a mapping between a source file, line and column --> a generated column
@@ -67,12 +84,13 @@ func (seg *Segment) Add(other Segment) Segment {
 
 // MapConfig represents the JSON structure of a source map in .map file
 type MapConfig struct {
-	Version    int      `json:"version"`
-	File       string   `json:"file"`
-	SourceRoot string   `json:"sourceRoot"`
-	Sources    []string `json:"sources"`
-	Names      []string `json:"names"`
-	Mappings   string   `json:"mappings"`
+	Version        int       `json:"version"`
+	File           string    `json:"file"`
+	SourceRoot     string    `json:"sourceRoot"`
+	Sources        []string  `json:"sources"`
+	SourcesContent []*string `json:"sourcesContent,omitempty"` // null for a source whose content isn't embedded
+	Names          []string  `json:"names"`
+	Mappings       string    `json:"mappings"`
 }
 
 // ParseSourceMapConfig parses a source map from a json string
@@ -85,9 +103,10 @@ func ParseSourceMapConfig(sourceMapJSON string) (*MapConfig, error) {
 	return sm, nil
 }
 
-// NewMapping wraps a sourceMappingURL
-func NewMapping(sourceMappingURL string, relativePath string, filepath string) *Mapping {
-	return &Mapping{sourceMappingURL, relativePath, filepath, nil, nil}
+// NewMapping wraps a sourceMappingURL, found in a file at relativePath (relative to bundleDir, the root-relative
+// directory of the bundle).  Until the source map is loaded, its source is assumed to be the file itself
+func NewMapping(sourceMappingURL string, relativePath string, bundleDir string, filepath string) *Mapping {
+	return &Mapping{sourceMappingURL, relativePath, relativePath, bundleDir, filepath, nil, nil, nil, nil, nil}
 }
 
 // NewMappingForTesting is ONLY intended for testing purposes
@@ -95,7 +114,47 @@ func NewMappingForTesting(config *MapConfig) *Mapping {
 	return &Mapping{config: config}
 }
 
-// RelativePath returns the path relative to the entry point
+// Names returns the names referred to by the mappings
+func (mapping *Mapping) Names() []string {
+	if mapping.config == nil {
+		return nil
+	}
+	return mapping.config.Names
+}
+
+// SourceContent gets the contents of the (first) source of the mapping, either from the sourcesContent of the map
+// itself, or by reading the file its sourceRoot and sources refer to
+func (mapping *Mapping) SourceContent() (string, bool) {
+	if mapping.config == nil || len(mapping.config.Sources) == 0 {
+		return "", false
+	}
+	if len(mapping.config.SourcesContent) > 0 && mapping.config.SourcesContent[0] != nil {
+		return *mapping.config.SourcesContent[0], true
+	}
+
+	sourcePath := mapping.config.Sources[0]
+	if mapping.config.SourceRoot != "" {
+		sourcePath = strings.TrimSuffix(mapping.config.SourceRoot, "/") + "/" + sourcePath
+	}
+	if !isRelativeURI(sourcePath) {
+		return "", false
+	}
+	contents, err := util.ReadContents(filepath.Join(filepath.Dir(mapping.filepath), filepath.FromSlash(sourcePath)))
+	return contents, err == nil
+}
+
+// Config gets the parsed source map, or nil if it hasn't been loaded (or failed to load)
+func (mapping *Mapping) Config() *MapConfig {
+	return mapping.config
+}
+
+// LoadError gets the reason the source map couldn't be loaded, e.g. the file is missing or isn't valid JSON
+func (mapping *Mapping) LoadError() error {
+	return mapping.loadErr
+}
+
+// RelativePath returns the path of the (first) source of the mapping, relative to the bundle.  Once loaded, this is
+// resolved from the sourceRoot and sources of the source map, and rewritten by the sourceRewrites of swarm.json
 func (mapping *Mapping) RelativePath() string {
 	return mapping.relativePath
 }
@@ -119,13 +178,53 @@ func (mapping *Mapping) LoadConfig() {
 	contents, err := util.ReadContents(mapping.filepath)
 	if err != nil {
 		log.Printf("Failed to load source map: %s", mapping.filepath)
+		mapping.loadErr = fmt.Errorf("failed to load source map '%s': %s", mapping.sourceMappingURL, err)
 		return
 	}
 
 	smapConfig, err := ParseSourceMapConfig(contents)
 	if err != nil {
 		log.Printf("ERROR parsing source map: " + mapping.relativePath)
+		mapping.loadErr = err
 		return
 	}
-	mapping.config = smapConfig
+	if problem := util.InvalidMappings(smapConfig.Mappings); problem != "" {
+		log.Printf("ERROR in source map: %s: %s", mapping.relativePath, problem)
+		mapping.loadErr = fmt.Errorf("invalid source map '%s': %s", mapping.sourceMappingURL, problem)
+		return // as if it had no source map
+	}
+	mapping.config, mapping.loadErr = smapConfig, nil
+	mapping.relativePath = mapping.resolveSourcePath()
+}
+
+// resolveSourcePath resolves the (first) source of the source map, which is relative to the source map itself, to a
+// path relative to the bundle, or an absolute url
+func (mapping *Mapping) resolveSourcePath() string {
+	if len(mapping.config.Sources) == 0 {
+		return mapping.generatedPath
+	}
+
+	sourceURL := mapping.config.Sources[0]
+	if mapping.config.SourceRoot != "" {
+		sourceURL = strings.TrimSuffix(mapping.config.SourceRoot, "/") + "/" + sourceURL
+	}
+	switch {
+	case isRelativeURI(sourceURL):
+		mapURL := path.Join(path.Dir(mapping.generatedPath), mapping.sourceMappingURL)
+		sourceURL = path.Join(mapping.bundleDir, path.Dir(mapURL), sourceURL)
+	case strings.HasPrefix(sourceURL, "/") && !strings.HasPrefix(sourceURL, "//"):
+		sourceURL = path.Clean(sourceURL[1:])
+	default:
+		return mapping.rewriter.Rewrite(sourceURL) // an absolute url
+	}
+
+	rootRelativePath := mapping.rewriter.Rewrite(sourceURL)
+	if !isRelativeURI(rootRelativePath) {
+		return rootRelativePath // rewritten as an absolute url
+	}
+	relativePath, err := filepath.Rel(filepath.FromSlash(mapping.bundleDir), filepath.FromSlash(rootRelativePath))
+	if err != nil {
+		return "/" + rootRelativePath
+	}
+	return filepath.ToSlash(relativePath)
 }
@@ -3,6 +3,8 @@ package source
 import (
 	"testing"
 
+	"github.com/mrcrowl/swarm/config"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -30,3 +32,45 @@ func TestParseSourceMap(t *testing.T) {
 	// parsed := parseMappings(value.Mappings)
 	// assert.Len(t, parsed, 19)
 }
+
+func TestMappingRelativePath(t *testing.T) {
+	rewriter, err := NewSourceRewriter([]*config.SourceRewriteConfig{
+		{Match: `^app/src/generated/(.*)\.js$`, Replace: "app/src/templates/$1.html"},
+		{Match: `^legacy/`, Replace: "https://cdn.example.com/legacy/"},
+	})
+	assert.Nil(t, err)
+
+	cases := map[string]struct {
+		relativePath string // of the generated file, relative to the bundle
+		sourceRoot   string
+		source       string
+		expected     string
+	}{
+		"alongside":     {"Util.js", "", "Util.ts", "Util.ts"},
+		"tsx":           {"ui/Button.js", "", "Button.tsx", "ui/Button.tsx"},
+		"js":            {"vendor/lib.js", "", "lib.js", "vendor/lib.js"},
+		"source root":   {"../lib/Lib.js", "../../ts/lib/", "Lib.ts", "../../ts/lib/Lib.ts"},
+		"root-relative": {"Util.js", "/app/src/ts", "Util.ts", "../ts/Util.ts"},
+		"absolute url":  {"Util.js", "webpack:///", "src/Util.ts", "webpack:///src/Util.ts"},
+		"rewritten":     {"../generated/View.js", "", "View.js", "../templates/View.html"},
+		"rewritten url": {"../../../legacy/Old.js", "", "Old.ts", "https://cdn.example.com/legacy/Old.ts"},
+		"no sources":    {"Util.js", "", "", "Util.js"},
+	}
+	for name, tc := range cases {
+		mapping := NewMapping("Map.js.map", tc.relativePath, "app/src/ep", "")
+		mapping.rewriter = rewriter
+		mapping.config = &MapConfig{SourceRoot: tc.sourceRoot, Sources: []string{tc.source}}
+		if tc.source == "" {
+			mapping.config.Sources = nil
+		}
+		assert.Equal(t, tc.expected, mapping.resolveSourcePath(), name)
+	}
+}
+
+func TestNewSourceRewriter(t *testing.T) {
+	_, err := NewSourceRewriter([]*config.SourceRewriteConfig{{Match: "(", Replace: ""}})
+	assert.NotNil(t, err)
+
+	var rewriter *SourceRewriter
+	assert.Equal(t, "a.ts", rewriter.Rewrite("a.ts"))
+}
@@ -0,0 +1,31 @@
+package source
+
+import (
+	"github.com/mrcrowl/swarm/util"
+)
+
+// RawFileContents describes a file which is bundled verbatim, e.g. one which is already a named System.register module
+type RawFileContents struct {
+	lines            []string
+	sourceMappingURL string
+}
+
+// BundleLines returns a list of lines ready to include in a SystemJSBundle
+func (rfc *RawFileContents) BundleLines() []string {
+	return rfc.lines
+}
+
+// SourceMappingURL returns the file's sourceMappingURL, if any
+func (rfc *RawFileContents) SourceMappingURL() string {
+	return rfc.sourceMappingURL
+}
+
+// ParseRawFileContents prepares a file to be bundled as-is, less any trailing sourceMappingURL
+func ParseRawFileContents(fileContents string) (*RawFileContents, error) {
+	lines := util.StringToLines(fileContents)
+	if len(lines) == 0 {
+		return &RawFileContents{lines, ""}, nil
+	}
+	sourceMappingURL, found := parseSourceMappingURL(lines[len(lines)-1])
+	return &RawFileContents{chooseBodyLines(lines, 0, found), sourceMappingURL}, nil
+}
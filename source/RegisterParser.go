@@ -0,0 +1,316 @@
+package source
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RegisterDeclaration describes the System.register(...) call at the start of a SystemJS module
+type RegisterDeclaration struct {
+	Name           string   // the module's name, if the register is named
+	Dependencies   []string // the (unquoted) dependencies
+	StartLine      int      // zero-based line on which System.register begins
+	StartColumn    int      // byte offset of System.register within its line
+	EndLine        int      // zero-based line on which the dependency array ends
+	EndColumn      int      // byte offset just beyond the dependency array's closing ]
+	DirectiveLines []int    // zero-based lines of any directive prologue, e.g. "use strict";
+}
+
+// RegisterParseError describes a malformed System.register declaration
+type RegisterParseError struct {
+	Filename string
+	Line     int // one-based
+	Message  string
+}
+
+func (err *RegisterParseError) Error() string {
+	return fmt.Sprintf("%s:%d: invalid System.register: %s", err.Filename, err.Line, err.Message)
+}
+
+const byteOrderMark = "\uFEFF"
+
+// Kinds of token recognised by the registerLexer
+const (
+	tokenEOF = iota
+	tokenIdentifier
+	tokenString
+	tokenPunctuation
+	tokenInvalid
+)
+
+type registerToken struct {
+	kind   int
+	value  string // identifier name, unquoted string or punctuation character
+	line   int
+	column int
+	end    int // offset just beyond the token
+}
+
+// registerLexer splits the start of a javascript file into tokens, skipping whitespace and comments
+type registerLexer struct {
+	src       string
+	pos       int
+	line      int
+	lineStart int
+}
+
+func (lex *registerLexer) next() (*registerToken, error) {
+	if err := lex.skipWhitespaceAndComments(); err != nil {
+		return nil, err
+	}
+
+	token := &registerToken{line: lex.line, column: lex.pos - lex.lineStart}
+	if lex.pos >= len(lex.src) {
+		token.kind = tokenEOF
+		token.end = lex.pos
+		return token, nil
+	}
+
+	c := lex.src[lex.pos]
+	switch {
+	case c == '"' || c == '\'':
+		value, err := lex.readString(c)
+		if err != nil {
+			return nil, err
+		}
+		token.kind, token.value = tokenString, value
+	case isIdentifierByte(c):
+		start := lex.pos
+		for lex.pos < len(lex.src) && isIdentifierByte(lex.src[lex.pos]) {
+			lex.pos++
+		}
+		token.kind, token.value = tokenIdentifier, lex.src[start:lex.pos]
+	case strings.IndexByte("()[],.;", c) >= 0:
+		token.kind, token.value = tokenPunctuation, string(c)
+		lex.pos++
+	default:
+		token.kind, token.value = tokenInvalid, string(c)
+		lex.pos++
+	}
+	token.end = lex.pos
+	return token, nil
+}
+
+func (lex *registerLexer) skipWhitespaceAndComments() error {
+	for lex.pos < len(lex.src) {
+		c := lex.src[lex.pos]
+		switch {
+		case c == '\n':
+			lex.newLine(lex.pos + 1)
+		case c == ' ' || c == '\t' || c == '\r' || c == '\v' || c == '\f':
+			lex.pos++
+		case strings.HasPrefix(lex.src[lex.pos:], byteOrderMark):
+			lex.pos += len(byteOrderMark)
+		case strings.HasPrefix(lex.src[lex.pos:], "//"):
+			end := strings.IndexByte(lex.src[lex.pos:], '\n')
+			if end < 0 {
+				lex.pos = len(lex.src)
+			} else {
+				lex.pos += end
+			}
+		case strings.HasPrefix(lex.src[lex.pos:], "/*"):
+			startLine := lex.line
+			end := strings.Index(lex.src[lex.pos+2:], "*/")
+			if end < 0 {
+				return lex.errorAt(startLine, "unterminated comment")
+			}
+			end += lex.pos + 4
+			for lex.pos < end {
+				if lex.src[lex.pos] == '\n' {
+					lex.newLine(lex.pos + 1)
+				} else {
+					lex.pos++
+				}
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (lex *registerLexer) newLine(pos int) {
+	lex.pos = pos
+	lex.line++
+	lex.lineStart = pos
+}
+
+// readString reads a string literal, decoding its escape sequences
+func (lex *registerLexer) readString(quote byte) (string, error) {
+	start := lex.pos
+	for lex.pos++; lex.pos < len(lex.src); lex.pos++ {
+		switch lex.src[lex.pos] {
+		case quote:
+			lex.pos++
+			return unquoteJSString(lex.src[start:lex.pos]), nil
+		case '\n':
+			return "", lex.errorAt(lex.line, "unterminated string")
+		case '\\':
+			lex.pos++
+		}
+	}
+	return "", lex.errorAt(lex.line, "unterminated string")
+}
+
+// unquoteJSString gets the value of a quoted javascript string, by converting it to a double-quoted Go string, which
+// strconv.Unquote can decode.  If it can't be decoded, it returns the text between the quotes
+func unquoteJSString(quoted string) string {
+	if len(quoted) < 2 {
+		return quoted
+	}
+	inner := quoted[1 : len(quoted)-1]
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(inner); i++ {
+		c := inner[i]
+		switch {
+		case c == '"':
+			sb.WriteString(`\"`)
+		case c != '\\' || i+1 == len(inner):
+			sb.WriteByte(c)
+		default:
+			i++
+			switch escaped := inner[i]; {
+			case escaped == 'u' && i+1 < len(inner) && inner[i+1] == '{': // e.g. \u{1F600}
+				end := strings.IndexByte(inner[i:], '}')
+				if end < 0 {
+					return inner
+				}
+				codePoint, err := strconv.ParseUint(inner[i+2:i+end], 16, 32)
+				if err != nil {
+					return inner
+				}
+				fmt.Fprintf(&sb, `\U%08x`, codePoint)
+				i += end
+			case strings.IndexByte(`"\bfnrtvxu`, escaped) >= 0:
+				sb.WriteByte('\\')
+				sb.WriteByte(escaped)
+			case escaped == '0' && (i+1 == len(inner) || inner[i+1] < '0' || inner[i+1] > '9'):
+				sb.WriteString(`\x00`)
+			case escaped == '\'':
+				sb.WriteByte('\'')
+			default:
+				sb.WriteByte(escaped) // javascript ignores the backslash of any other escape, e.g. \/
+			}
+		}
+	}
+	sb.WriteByte('"')
+
+	if value, err := strconv.Unquote(sb.String()); err == nil {
+		return value
+	}
+	return inner
+}
+
+func (lex *registerLexer) errorAt(line int, message string) error {
+	return &RegisterParseError{Line: line + 1, Message: message}
+}
+
+func isIdentifierByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '$' || c >= 0x80
+}
+
+// ParseRegisterDeclaration finds the System.register(...) call at the start of a javascript file, after any comments
+// and directives.  Returns nil without an error if the file doesn't start with one, or an error if it is malformed
+func ParseRegisterDeclaration(filename string, javascript string) (*RegisterDeclaration, error) {
+	decl, err := parseRegisterDeclaration(&registerLexer{src: javascript})
+	if parseErr, ok := err.(*RegisterParseError); ok {
+		parseErr.Filename = filename
+	}
+	return decl, err
+}
+
+func parseRegisterDeclaration(lex *registerLexer) (*RegisterDeclaration, error) {
+	decl := &RegisterDeclaration{}
+
+	// directive prologue, e.g. "use strict";
+	token, err := lex.next()
+	for err == nil && token.kind == tokenString {
+		decl.DirectiveLines = append(decl.DirectiveLines, token.line)
+		if token, err = lex.next(); err == nil && token.kind == tokenPunctuation && token.value == ";" {
+			token, err = lex.next()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// System.register(
+	if token.kind != tokenIdentifier || token.value != "System" {
+		return nil, nil
+	}
+	decl.StartLine, decl.StartColumn = token.line, token.column
+	for _, expected := range []string{".", "register", "("} {
+		if token, err = lex.next(); err != nil {
+			return nil, err
+		}
+		if token.value != expected || token.kind == tokenString {
+			return nil, nil // some other use of System, e.g. System.config(...)
+		}
+	}
+
+	// optional module name
+	if token, err = lex.next(); err != nil {
+		return nil, err
+	}
+	if token.kind == tokenString {
+		decl.Name = token.value
+		if token, err = lex.expect(","); err != nil {
+			return nil, err
+		}
+		if token, err = lex.next(); err != nil {
+			return nil, err
+		}
+	}
+	if token.kind != tokenPunctuation || token.value != "[" {
+		return nil, lex.unexpected(token, "'['")
+	}
+
+	// dependency array, which may contain a trailing comma
+	decl.Dependencies = []string{}
+	for {
+		if token, err = lex.next(); err != nil {
+			return nil, err
+		}
+		if token.kind == tokenPunctuation && token.value == "]" {
+			break
+		}
+		if token.kind != tokenString {
+			return nil, lex.unexpected(token, "a dependency string or ']'")
+		}
+		decl.Dependencies = append(decl.Dependencies, token.value)
+
+		if token, err = lex.next(); err != nil {
+			return nil, err
+		}
+		if token.kind == tokenPunctuation && token.value == "]" {
+			break
+		}
+		if token.kind != tokenPunctuation || token.value != "," {
+			return nil, lex.unexpected(token, "',' or ']'")
+		}
+	}
+
+	decl.EndLine, decl.EndColumn = token.line, token.end-lex.lineStart
+	return decl, nil
+}
+
+// expect reads a punctuation token, returning an error if it is something else
+func (lex *registerLexer) expect(punctuation string) (*registerToken, error) {
+	token, err := lex.next()
+	if err != nil {
+		return nil, err
+	}
+	if token.kind != tokenPunctuation || token.value != punctuation {
+		return nil, lex.unexpected(token, "'"+punctuation+"'")
+	}
+	return token, nil
+}
+
+func (lex *registerLexer) unexpected(token *registerToken, expected string) error {
+	if token.kind == tokenEOF {
+		return lex.errorAt(token.line, "unexpected end of file, expected "+expected)
+	}
+	return lex.errorAt(token.line, fmt.Sprintf("unexpected '%s', expected %s", token.value, expected))
+}
@@ -0,0 +1,125 @@
+package source
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRegisterDeclaration(t *testing.T) {
+	cases := map[string]struct {
+		source       string
+		name         string
+		dependencies []string
+		startLine    int
+		endLine      int
+		endColumn    int
+		directives   []int
+	}{
+		"standard": {
+			source:       `System.register(["tslib", "./a"], function (exports_1, context_1) {`,
+			dependencies: []string{"tslib", "./a"},
+			endColumn:    32,
+		},
+		"no dependencies": {
+			source:       `System.register([], function (exports_1, context_1) {`,
+			dependencies: []string{},
+			endColumn:    18,
+		},
+		"named": {
+			source:       `System.register("app/a.js", ['./b'], function (exports_1, context_1) {`,
+			name:         "app/a.js",
+			dependencies: []string{"./b"},
+			endColumn:    35,
+		},
+		"single quotes": {
+			source:       `System.register(['./a', "./b"], function (e, c) {`,
+			dependencies: []string{"./a", "./b"},
+			endColumn:    30,
+		},
+		"escapes": {
+			source:       `System.register(["./a\"b", './c\'d', "./\u0065\x66\u{67}", '\/h"i'], function (e, c) {`,
+			dependencies: []string{`./a"b`, `./c'd`, "./efg", `/h"i`},
+			endColumn:    67,
+		},
+		"minified": {
+			source:       `System.register(["a","b"],function(e,t){"use strict";return{}});`,
+			dependencies: []string{"a", "b"},
+			endColumn:    25,
+		},
+		"multi-line": {
+			source:       "System.register([\n    \"./a\",\n    \"./b\", // trailing comment\n], function (exports_1, context_1) {",
+			dependencies: []string{"./a", "./b"},
+			endLine:      3,
+			endColumn:    1,
+		},
+		"leading whitespace and comments": {
+			source:       "  /* licence\n  text */\n\t// note\n    System.register([\"./a\"], function (exports_1, context_1) {",
+			dependencies: []string{"./a"},
+			startLine:    3,
+			endLine:      3,
+			endColumn:    27,
+		},
+		"use strict": {
+			source:       "\"use strict\";\nSystem.register([\"./a\" /* why */], function (exports_1, context_1) {",
+			dependencies: []string{"./a"},
+			startLine:    1,
+			endLine:      1,
+			endColumn:    33,
+			directives:   []int{0},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			decl, err := ParseRegisterDeclaration("a.js", tc.source)
+			assert.Nil(t, err)
+			if assert.NotNil(t, decl) {
+				assert.Equal(t, tc.name, decl.Name)
+				assert.Equal(t, tc.dependencies, decl.Dependencies)
+				assert.Equal(t, tc.startLine, decl.StartLine)
+				assert.Equal(t, tc.endLine, decl.EndLine)
+				assert.Equal(t, tc.endColumn, decl.EndColumn)
+				assert.Equal(t, tc.directives, decl.DirectiveLines)
+			}
+		})
+	}
+}
+
+func TestParseRegisterDeclarationNotRegister(t *testing.T) {
+	sources := []string{
+		"",
+		"// only a comment",
+		"window.z = true;",
+		"System.config({});",
+		"<div>Hello</div>",
+		"var x = System.register([\"a\"], function () {});",
+	}
+	for _, source := range sources {
+		decl, err := ParseRegisterDeclaration("a.js", source)
+		assert.Nil(t, err, source)
+		assert.Nil(t, decl, source)
+	}
+}
+
+func TestParseRegisterDeclarationErrors(t *testing.T) {
+	cases := map[string]struct {
+		source   string
+		expected string
+	}{
+		"wrong bracket":      {source: `System.register(][, function () {`, expected: "a.js:1: invalid System.register: unexpected ']', expected '['"},
+		"missing dependency": {source: `System.register([, function () {`, expected: "a.js:1: invalid System.register: unexpected ',', expected a dependency string or ']'"},
+		"missing comma":      {source: "System.register([\n\"a\"\n\"b\"], function () {", expected: "a.js:3: invalid System.register: unexpected 'b', expected ',' or ']'"},
+		"unterminated":       {source: "System.register([\n\"a", expected: "a.js:2: invalid System.register: unterminated string"},
+		"end of file":        {source: "System.register([\"a\",", expected: "a.js:1: invalid System.register: unexpected end of file, expected a dependency string or ']'"},
+		"unnamed comma":      {source: `System.register("name" ["a"], function () {`, expected: "a.js:1: invalid System.register: unexpected '[', expected ','"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			decl, err := ParseRegisterDeclaration("a.js", tc.source)
+			assert.Nil(t, decl)
+			if assert.NotNil(t, err) {
+				assert.Equal(t, tc.expected, err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package source
+
+import (
+	"fmt"
+	"regexp"
+	"github.com/mrcrowl/swarm/config"
+)
+
+// SourceRewriter rewrites the sources of the bundled source maps, according to the sourceRewrites of swarm.json
+type SourceRewriter struct {
+	rules []*sourceRewriteRule
+}
+
+type sourceRewriteRule struct {
+	pattern *regexp.Regexp
+	replace string
+}
+
+// NewSourceRewriter creates a SourceRewriter from the sourceRewrites section of swarm.json
+func NewSourceRewriter(configs []*config.SourceRewriteConfig) (*SourceRewriter, error) {
+	rewriter := &SourceRewriter{}
+	for _, rewriteConfig := range configs {
+		pattern, err := regexp.Compile(rewriteConfig.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid source rewrite '%s': %s", rewriteConfig.Match, err)
+		}
+		rewriter.rules = append(rewriter.rules, &sourceRewriteRule{pattern, rewriteConfig.Replace})
+	}
+	return rewriter, nil
+}
+
+// Rewrite rewrites a root-relative source path with the first rule that matches it, if any
+func (rewriter *SourceRewriter) Rewrite(sourcePath string) string {
+	if rewriter == nil {
+		return sourcePath
+	}
+	for _, rule := range rewriter.rules {
+		if rule.pattern.MatchString(sourcePath) {
+			return rule.pattern.ReplaceAllString(sourcePath, rule.replace)
+		}
+	}
+	return sourcePath
+}
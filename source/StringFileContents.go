@@ -1,7 +1,11 @@
 package source
 
 import (
+	"encoding/base64"
 	"fmt"
+	"mime"
+	"path"
+	"strings"
 	"github.com/mrcrowl/swarm/util"
 )
 
@@ -42,3 +46,17 @@ func ParseStringFileContents(name string, fileContents string) (*StringFileConte
 	lines := util.StringToLines(body)
 	return &StringFileContents{lines}, nil
 }
+
+// ParseDataURIFileContents prepares a (possibly binary) file as a module which exports a base64 data URI
+func ParseDataURIFileContents(name string, data []byte) (*StringFileContents, error) {
+	return ParseStringFileContents(name, dataURI(name, data))
+}
+
+// dataURI encodes a file as a base64 data URI, with a mime type chosen by the extension of its name
+func dataURI(name string, data []byte) string {
+	mimeType := strings.Replace(mime.TypeByExtension(path.Ext(name)), " ", "", -1)
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
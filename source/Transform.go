@@ -0,0 +1,154 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+	"github.com/mrcrowl/swarm/config"
+)
+
+const defaultTransformTimeout = 30 * time.Second
+
+// transformWaitDelay limits how long a timed out command's output is waited for, once it has been killed, in case
+// something it started still holds it open
+const transformWaitDelay = time.Second
+
+// transformInputPlaceholder is replaced with the path of the file being transformed
+const transformInputPlaceholder = "{in}"
+
+// Transform pipes the contents of matching files through an external command, e.g. a compiler, then loads its output
+type Transform struct {
+	pattern *filePattern
+	command string
+	output  string
+	timeout time.Duration
+}
+
+// TransformError describes a transform command which failed, or didn't finish in time
+type TransformError struct {
+	Filename string
+	Command  string
+	Message  string
+}
+
+func (err *TransformError) Error() string {
+	return fmt.Sprintf("%s: transform '%s' failed: %s", err.Filename, err.Command, err.Message)
+}
+
+// NewTransform creates a Transform from its configuration in swarm.json
+func NewTransform(transformConfig *config.TransformConfig) (*Transform, error) {
+	if transformConfig.Command == "" {
+		return nil, fmt.Errorf("transform for '%s' has no command", transformConfig.Match)
+	}
+	if _, found := lookupLoader(transformConfig.Output); !found {
+		return nil, fmt.Errorf("unknown output '%s' for transform of '%s'", transformConfig.Output, transformConfig.Match)
+	}
+	pattern, err := newFilePattern(transformConfig.Match)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := defaultTransformTimeout
+	if transformConfig.TimeoutMillis > 0 {
+		timeout = time.Duration(transformConfig.TimeoutMillis) * time.Millisecond
+	}
+	return &Transform{pattern, transformConfig.Command, transformConfig.Output, timeout}, nil
+}
+
+// Run runs the transform's command for a file, with the file's contents on stdin, and returns its output
+func (transform *Transform) Run(file *File, contents string) (string, error) {
+	command := strings.Replace(transform.command, transformInputPlaceholder, quoteShellArgument(file.Filepath), -1)
+	ctx, cancel := context.WithTimeout(context.Background(), transform.timeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, command)
+	cmd.WaitDelay = transformWaitDelay
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = strings.NewReader(contents)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		return "", &TransformError{file.Filepath, transform.command, fmt.Sprintf("timed out after %s", transform.timeout)}
+	case err != nil:
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return "", &TransformError{file.Filepath, transform.command, message}
+	}
+	return stdout.String(), nil
+}
+
+// transformResults remembers the output of each file's transform (or the error if it failed), until the file's size or
+// modification time changes.  It is shared by every File read from a workspace, since the dependency walker reads a
+// file's dependencies through one File, and the FileSet loads its contents through another
+type transformResults struct {
+	mutex   sync.Mutex
+	results map[string]*transformResult // by filepath
+}
+
+type transformResult struct {
+	mutex   sync.Mutex // held while the command runs, so that it only runs once for concurrent requests
+	done    bool
+	size    int64
+	modTime time.Time
+	output  string
+	err     error
+}
+
+func newTransformResults() *transformResults {
+	return &transformResults{results: make(map[string]*transformResult)}
+}
+
+// run runs a transform for a file, unless it has already run since the file last changed
+func (tr *transformResults) run(transform *Transform, file *File, contents string) (string, error) {
+	info, err := os.Stat(file.Filepath)
+	if err != nil {
+		return transform.Run(file, contents)
+	}
+
+	tr.mutex.Lock()
+	result, found := tr.results[file.Filepath]
+	if !found {
+		result = &transformResult{}
+		tr.results[file.Filepath] = result
+	}
+	tr.mutex.Unlock()
+
+	result.mutex.Lock()
+	defer result.mutex.Unlock()
+	if !result.done || result.size != info.Size() || !result.modTime.Equal(info.ModTime()) {
+		result.output, result.err = transform.Run(file, contents)
+		result.done, result.size, result.modTime = true, info.Size(), info.ModTime()
+	}
+	return result.output, result.err
+}
+
+func quoteShellArgument(argument string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + argument + `"`
+	}
+	return "'" + strings.Replace(argument, "'", `'\''`, -1) + "'"
+}
+
+// transformingLoader runs a file's transform, then loads the command's output
+type transformingLoader struct {
+	transform *Transform
+	loader    Loader
+}
+
+func (tl *transformingLoader) Load(file *File, contents string, runtimeConfig *config.RuntimeConfig) (FileContents, error) {
+	output, err := file.transforms.run(tl.transform, file, contents)
+	if err != nil {
+		return nil, err
+	}
+	return tl.loader.Load(file, output, runtimeConfig)
+}
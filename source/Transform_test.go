@@ -0,0 +1,122 @@
+package source
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTransformRegistry(t *testing.T, transforms ...*config.TransformConfig) *LoaderRegistry {
+	registry, err := NewLoaderRegistry(nil)
+	assert.Nil(t, err)
+	assert.Nil(t, registry.AddTransforms(transforms))
+	return registry
+}
+
+func TestTransformToCSS(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a unix shell")
+	}
+	setup()
+	defer teardown()
+	registry := createTransformRegistry(t, &config.TransformConfig{Match: "**/*.scss", Command: "sed 's/$c/red/'", Output: LoaderCSS})
+
+	f := getSampleFile("blah.scss", ".scss", "a { color: $c; }")
+	f.loaders = registry
+	assert.Equal(t, LoaderCSS, registry.LoaderName(f))
+	f.EnsureLoaded(nil)
+	assert.Nil(t, f.LoadError())
+	assert.Equal(t, "a { color: red; }", f.RawContents().(*CSSFileContents).RawCSSContent())
+	assert.Contains(t, registry.Extensions(), ".scss")
+}
+
+func TestTransformToJavascript(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a unix shell")
+	}
+	setup()
+	defer teardown()
+	registry := createTransformRegistry(t, &config.TransformConfig{Match: ".ts", Command: "sed 's/: string//' {in}", Output: LoaderJS})
+	assert.Equal(t, []string{".ts"}, registry.ScriptExtensions())
+
+	f := getSampleFile("blah", ".ts", "import { b } from './b';\nexport const a: string = b;")
+	f.loaders = registry
+	dependencies, found, err := f.RegisterDependencies()
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []string{"./b"}, dependencies)
+
+	f.EnsureLoaded(nil)
+	assert.Contains(t, strings.Join(f.BundleBody(), "\n"), `const a = b; exports_1("a", a);`)
+}
+
+func TestTransformFailures(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a unix shell")
+	}
+	setup()
+	defer teardown()
+
+	cases := map[string]struct {
+		transform *config.TransformConfig
+		message   string
+	}{
+		"non-zero exit": {&config.TransformConfig{Match: ".scss", Command: "echo 'bad syntax' >&2; exit 1", Output: LoaderCSS}, "bad syntax"},
+		"timeout":       {&config.TransformConfig{Match: ".scss", Command: "sleep 5", Output: LoaderCSS, TimeoutMillis: 50}, "timed out after 50ms"},
+	}
+
+	for name, c := range cases {
+		f := getSampleFile("blah.scss", ".scss", "a {}")
+		f.loaders = createTransformRegistry(t, c.transform)
+		start := time.Now()
+		f.EnsureLoaded(nil)
+		assert.True(t, time.Since(start) < time.Second, "%s took %s", name, time.Since(start))
+		assert.IsType(t, &FailedFileContents{}, f.RawContents(), name)
+		if assert.NotNil(t, f.LoadError(), name) {
+			assert.Contains(t, f.LoadError().Error(), f.Filepath+": transform '"+c.transform.Command+"' failed: "+c.message, name)
+		}
+	}
+}
+
+func TestTransformFailureIsRemembered(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a unix shell")
+	}
+	setup()
+	defer teardown()
+
+	runs := filepath.Join(temppath, "runs.txt")
+	f := getSampleFile("blah.scss", ".scss", "a {}")
+	f.loaders = createTransformRegistry(t, &config.TransformConfig{Match: ".scss", Command: "echo run >> " + quoteShellArgument(runs) + "; exit 1", Output: LoaderCSS})
+	_, _, err := f.RegisterDependencies()
+	assert.NotNil(t, err)
+	f.EnsureLoaded(nil)
+	assert.NotNil(t, f.LoadError())
+	assert.Equal(t, "run\n", testutil.ReadTextFile(temppath, "runs.txt"))
+
+	// another File for the same path, e.g. in the FileSet rather than the dependency walker, shares the result
+	another := newFile(f.ID, f.Filepath)
+	another.loaders, another.transforms = f.loaders, f.transforms
+	another.EnsureLoaded(nil)
+	assert.NotNil(t, another.LoadError())
+	assert.Equal(t, "run\n", testutil.ReadTextFile(temppath, "runs.txt"))
+
+	// until the file changes
+	testutil.WriteTextFile(temppath, "blah.scss", "a { b: c }")
+	f.UnloadContents()
+	f.EnsureLoaded(nil)
+	assert.Equal(t, "run\nrun\n", testutil.ReadTextFile(temppath, "runs.txt"))
+}
+
+func TestNewTransformInvalid(t *testing.T) {
+	_, err := NewTransform(&config.TransformConfig{Match: ".scss", Command: "sass {in}", Output: "sass"})
+	assert.NotNil(t, err)
+	_, err = NewTransform(&config.TransformConfig{Match: ".scss", Output: LoaderCSS})
+	assert.NotNil(t, err)
+}
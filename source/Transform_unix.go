@@ -0,0 +1,20 @@
+// +build !windows
+
+package source
+
+import (
+	"context"
+	"os/exec"
+	"syscall"
+)
+
+// shellCommand runs a command with sh, in a process group of its own, so that when the context is done, the whole
+// group is killed, rather than only the shell (which would leave e.g. a compiler in watch mode running)
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	return cmd
+}
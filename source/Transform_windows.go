@@ -0,0 +1,14 @@
+// +build windows
+
+package source
+
+import (
+	"context"
+	"os/exec"
+)
+
+// shellCommand runs a command with cmd.  When the context is done, only cmd itself is killed, so the output of anything
+// it started is only waited for until the command's WaitDelay
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	return exec.CommandContext(ctx, "cmd", "/C", command)
+}
@@ -4,13 +4,25 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"github.com/mrcrowl/swarm/cache"
 	"github.com/mrcrowl/swarm/config"
 )
 
+// Names of the cache settings that track the interpolation values, and the loaders and transforms
+const (
+	interpolationCacheSetting = "interpolationValues"
+	loadersCacheSetting       = "loaders"
+)
+
 // Workspace is
 type Workspace struct {
-	rootPath string
+	rootPath   string
+	cache      *cache.Cache
+	loaders    *LoaderRegistry
+	rewriter   *SourceRewriter
+	transforms *transformResults
 }
 
 var explicitSep = os.PathSeparator
@@ -22,7 +34,8 @@ func emulateUnix() {
 // NewWorkspace returns a new workspace for the given rootpath
 func NewWorkspace(rootPath string) *Workspace {
 	return &Workspace{
-		rootPath: normaliseFilepath(rootPath, true),
+		rootPath:   normaliseFilepath(rootPath, true),
+		transforms: newTransformResults(),
 	}
 }
 
@@ -52,6 +65,36 @@ func (ws *Workspace) RootPath() string {
 	return ws.rootPath
 }
 
+// SetCache attaches a persistent build cache, which is shared by every file read from the workspace
+func (ws *Workspace) SetCache(buildCache *cache.Cache) {
+	ws.cache = buildCache
+	ws.cache.RequireSetting(loadersCacheSetting, ws.Loaders().String())
+}
+
+// Cache returns the workspace's persistent build cache, which may be nil
+func (ws *Workspace) Cache() *cache.Cache {
+	return ws.cache
+}
+
+// SetLoaders chooses the loaders for files read from the workspace, in place of the default loaders
+func (ws *Workspace) SetLoaders(loaders *LoaderRegistry) {
+	ws.loaders = loaders
+	ws.cache.RequireSetting(loadersCacheSetting, ws.Loaders().String())
+}
+
+// Loaders returns the registry which chooses the loaders for files read from the workspace
+func (ws *Workspace) Loaders() *LoaderRegistry {
+	if ws.loaders == nil {
+		return defaultLoaderRegistry
+	}
+	return ws.loaders
+}
+
+// SetSourceRewriter chooses how the sources of the source maps of files read from the workspace are rewritten
+func (ws *Workspace) SetSourceRewriter(rewriter *SourceRewriter) {
+	ws.rewriter = rewriter
+}
+
 // ReadInterpolationValues returns a map of key/value pairs that can be interpolated into import paths
 func (ws *Workspace) ReadInterpolationValues(config *config.RuntimeConfig) map[string]string {
 	// TODO: Config.js is hard-coded for now
@@ -64,6 +107,7 @@ func (ws *Workspace) ReadInterpolationValues(config *config.RuntimeConfig) map[s
 
 	file.EnsureLoaded(config)
 	values := readInterpolationValues("Config", file.RawContents().BundleLines())
+	ws.cache.RequireSetting(interpolationCacheSetting, encodeInterpolationValues(values))
 	return values
 }
 
@@ -71,7 +115,7 @@ func (ws *Workspace) ReadInterpolationValues(config *config.RuntimeConfig) map[s
 func (ws *Workspace) ReadSourceFile(imp *Import) (*File, error) {
 	exists := false
 	absoluteFilePath := ""
-	for _, ext := range []string{"", ".js"} {
+	for _, ext := range append([]string{"", ".js"}, ws.Loaders().ScriptExtensions()...) {
 		absoluteFilePath = filepath.Join(ws.rootPath, (imp.Path() + ext))
 		if _, err := os.Stat(absoluteFilePath); os.IsNotExist(err) {
 			continue
@@ -81,7 +125,12 @@ func (ws *Workspace) ReadSourceFile(imp *Import) (*File, error) {
 	}
 
 	if exists {
-		return newFile(imp.Path(), absoluteFilePath), nil
+		file := newFile(imp.Path(), absoluteFilePath)
+		file.cache = ws.cache
+		file.loaders = ws.loaders
+		file.sourceRewriter = ws.rewriter
+		file.transforms = ws.transforms
+		return file, nil
 	}
 
 	return nil, os.ErrNotExist
@@ -97,3 +146,18 @@ func (ws *Workspace) ToRelativePath(absoluteFilepath string) (string, bool) {
 
 	return "", false
 }
+
+// encodeInterpolationValues formats interpolation values as a stable string
+func encodeInterpolationValues(values map[string]string) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&sb, "%s=%s\n", key, values[key])
+	}
+	return sb.String()
+}
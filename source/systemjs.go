@@ -2,35 +2,16 @@ package source
 
 import (
 	"strings"
+	"github.com/mrcrowl/swarm/util"
 )
 
-const systemJSRegisterPrefix = "System.register(["
-
-// ParseRegisterDependencies parses the first line of a SystemJS formatted file and returns the import dependencies
-func ParseRegisterDependencies(line string, trimQuotes bool) ([]string, bool) {
-	if !strings.HasPrefix(line, systemJSRegisterPrefix) {
-		return nil, false // not a register line
-	}
-
-	openPos := strings.Index(line, "[")
-	closePos := strings.LastIndex(line, "]")
-	if openPos < 0 || closePos < 0 || openPos > closePos {
-		return nil, false // not a register line
-	}
-
-	if closePos == (openPos + 1) {
-		return nil, true // no imports
+// quoteDependencies wraps each dependency in double quotes, escaping it as necessary, ready to be written into a bundle
+func quoteDependencies(dependencies []string) []string {
+	quoted := make([]string, len(dependencies))
+	for i, dependency := range dependencies {
+		quoted[i] = util.JSONEncodeString(dependency)
 	}
-
-	dependencySlice := line[(openPos + 1):closePos]
-	dependencies := strings.Split(dependencySlice, ", ")
-	if trimQuotes {
-		for i, quotedDependency := range dependencies {
-			dependencies[i] = strings.Trim(quotedDependency, "\"")
-		}
-	}
-
-	return dependencies, true // has imports
+	return quoted
 }
 
 func skipPreamble(lines []string) ([]string, int) {
@@ -173,3 +173,40 @@ blah blah something else`
 	assert.Len(t, preamble, 4)
 	assert.Equal(t, 4, numLines)
 }
+
+func TestParseRegisterMultiLine(t *testing.T) {
+	source := `"use strict";
+System.register([
+    "tslib",
+    './a'
+], function (exports_1, context_1) {
+});
+//# sourceMappingURL=abcd.js.map`
+
+	elems, err := ParseJSFileContents("abcd", source)
+	assert.Nil(t, err)
+	assert.True(t, elems.isSystemJS)
+	assert.Equal(t, []string{`"tslib"`, `"./a"`}, elems.imports)
+	assert.Equal(t, []string{
+		"",
+		`System.register("abcd.js", ["tslib", "./a"], function (exports_1, context_1) {`,
+		"",
+		"",
+		"",
+		"});",
+	}, elems.body)
+	assert.Equal(t, "abcd.js.map", elems.sourceMappingURL)
+}
+
+func TestParseRegisterNamedMinified(t *testing.T) {
+	source := `System.register("lib",["a"],function(e,t){return{}});`
+
+	elems, err := ParseJSFileContents("abcd", source)
+	assert.Nil(t, err)
+	assert.True(t, elems.isSystemJS)
+	assert.Equal(t, []string{`System.register("abcd.js", ["a"],function(e,t){return{}});`}, elems.body)
+}
+
+func TestQuoteDependencies(t *testing.T) {
+	assert.Equal(t, []string{`"./a"`, `"./a\"b"`, `"./c\\d"`, `"./<e>"`}, quoteDependencies([]string{"./a", `./a"b`, `./c\d`, "./<e>"}))
+}
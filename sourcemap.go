@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mrcrowl/swarm/config"
+	"github.com/mrcrowl/swarm/util"
+)
+
+const sourcemapCommand = "sourcemap"
+
+// runSourceMap looks up a position of a module's bundle in its source map, or verifies the source maps of a build
+func runSourceMap(args []string) {
+	if (len(args) == 0 && !*verifyFlag) || (*atFlag == "") == !*verifyFlag {
+		fmt.Println("Usage: swarm sourcemap <entry> [build] --at line:col")
+		fmt.Println("       swarm sourcemap [entry [build]] --verify")
+		os.Exit(1)
+	}
+
+	entry := ""
+	if len(args) > 0 {
+		entry, args = args[0], args[1:]
+	}
+	_, runtimeConfig, _, moduleSet := loadModuleSet(args)
+
+	if *verifyFlag {
+		runtimeConfig.SourceMapFormat = config.SourceMapFormatFlattened // the sections of an index map aren't composed
+		reports, err := moduleSet.VerifySourceMaps(entry)
+		util.ExitIfError(err, "%s", err)
+
+		failed := false
+		for _, report := range reports {
+			if len(report.Problems) == 0 {
+				fmt.Printf("Source map of module '%s' is valid\n", report.Module)
+				continue
+			}
+			fmt.Printf("Problems in the source map of module '%s':\n", report.Module)
+			for _, problem := range report.Problems {
+				fmt.Printf("   %s\n", problem)
+			}
+			failed = true
+		}
+		if failed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	line, column, err := parseLineColumn(*atFlag)
+	util.ExitIfError(err, "Invalid --at '%s': %s", *atFlag, err)
+	position, err := moduleSet.LookupSourcePosition(entry, line-1, column-1)
+	util.ExitIfError(err, "%s", err)
+
+	fmt.Printf("%s:%d:%d\n", position.Source, position.Line+1, position.Column+1)
+	if position.Name != "" {
+		fmt.Printf("   name: %s\n", position.Name)
+	}
+}
+
+// parseLineColumn parses a one-based line:col, as shown by DevTools
+func parseLineColumn(at string) (int, int, error) {
+	parts := strings.Split(at, ":")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("expected line:col")
+	}
+	line, err := strconv.Atoi(parts[0])
+	if err != nil || line < 1 {
+		return 0, 0, fmt.Errorf("line must be a number from 1")
+	}
+	column, err := strconv.Atoi(parts[1])
+	if err != nil || column < 1 {
+		return 0, 0, fmt.Errorf("column must be a number from 1")
+	}
+	return line, column, nil
+}
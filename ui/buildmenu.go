@@ -46,6 +46,32 @@ func ChooseBuild(builds map[string]*config.RuntimeConfig, tailArgs []string) *co
 	return selectedBuild
 }
 
+// RequireBuild finds the build named by the first command line argument after a command which mustn't prompt for one.
+// If the name is missing or unknown, it lists the names of the builds and exits
+func RequireBuild(command string, builds map[string]*config.RuntimeConfig, tailArgs []string) *config.RuntimeConfig {
+	if len(tailArgs) > 0 {
+		if build, found := builds[tailArgs[0]]; found {
+			return build
+		}
+		fmt.Printf("Unknown build '%s'\n", tailArgs[0])
+	} else {
+		fmt.Println("No build specified")
+	}
+
+	if len(builds) == 0 {
+		fmt.Println("No builds found")
+		os.Exit(1)
+	}
+	buildNames := enumerateBuildNames(builds)
+	fmt.Println("Choose one of these builds:")
+	for _, name := range buildNames {
+		fmt.Printf("  %s\n", name)
+	}
+	fmt.Printf("     e.g. %s%s %s %s\n", executablePrompt(), executableName(), command, buildNames[0])
+	os.Exit(1)
+	return nil
+}
+
 // chooseBuildFromMenu presents a menu to select a build
 func chooseBuildFromMenu(builds map[string]*config.RuntimeConfig) *config.RuntimeConfig {
 	buildNames := enumerateBuildNames(builds)
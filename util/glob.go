@@ -0,0 +1,35 @@
+package util
+
+import (
+	"regexp"
+	"strings"
+)
+
+// CompileGlob converts a glob into a regular expression which matches slash-separated paths.  A * matches within a
+// single path segment, ** matches across any number of segments and ? matches a single character.  A glob without a
+// slash matches the final segment (filename) of a path, in any directory
+func CompileGlob(glob string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	if !strings.Contains(glob, "/") {
+		sb.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case strings.HasPrefix(glob[i:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
@@ -0,0 +1,34 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileGlob(t *testing.T) {
+	cases := []struct {
+		glob    string
+		path    string
+		matches bool
+	}{
+		{"*.svg", "icon.svg", true},
+		{"*.svg", "app/img/icon.svg", true},
+		{"*.svg", "app/img/icon.svg.bak", false},
+		{"app/*.md", "app/readme.md", true},
+		{"app/*.md", "app/docs/readme.md", false},
+		{"app/**/*.md", "app/readme.md", true},
+		{"app/**/*.md", "app/docs/guide/readme.md", true},
+		{"**/*.scss", "app/src/theme.scss", true},
+		{"app/**", "app/src/a.js", true},
+		{"app/?.js", "app/a.js", true},
+		{"app/?.js", "app/ab.js", false},
+		{"app/a+b.js", "app/a+b.js", true},
+	}
+
+	for _, c := range cases {
+		re, err := CompileGlob(c.glob)
+		assert.Nil(t, err)
+		assert.Equal(t, c.matches, re.MatchString(c.path), c.glob+" "+c.path)
+	}
+}
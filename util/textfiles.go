@@ -18,6 +18,24 @@ func ReadContents(filepath string) (string, error) {
 	return trimByteOrderMark(string(bytes)), nil
 }
 
+// ReadPrefix reads up to n bytes from the start of a text file as a string, reporting whether that is the entire file
+func ReadPrefix(filepath string, n int) (string, bool, error) {
+	f, err := os.Open(filepath)
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+
+	buffer := make([]byte, n+1) // one more, to tell whether anything follows
+	read, err := io.ReadFull(f, buffer)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		return trimByteOrderMark(string(buffer[:read])), true, nil
+	} else if err != nil {
+		return "", false, err
+	}
+	return trimByteOrderMark(string(buffer[:n])), false, nil
+}
+
 func trimByteOrderMark(s string) string {
 	if len(s) > 3 &&
 		s[0] == 0xef &&
@@ -29,42 +47,6 @@ func trimByteOrderMark(s string) string {
 	return s
 }
 
-// ReadFirstLine reads the first line of a text file as a string
-func ReadFirstLine(filepath string) (string, error) {
-	f, err := os.OpenFile(filepath, os.O_RDONLY, os.ModePerm)
-	if err != nil {
-		return "", err
-	}
-	defer f.Close()
-
-	sc := bufio.NewScanner(f)
-	firstLine := readFirstLineBeyondComments(sc)
-
-	return firstLine, nil
-}
-
-func readFirstLineBeyondComments(sc *bufio.Scanner) string {
-	inBlockComment := false
-	for sc.Scan() {
-		line := sc.Text()
-		if strings.HasPrefix(line, "//") {
-			continue
-		}
-		if strings.HasPrefix(line, "/*") {
-			inBlockComment = true
-			continue
-		}
-		if inBlockComment {
-			if strings.Contains(line, "*/") {
-				inBlockComment = false
-			}
-			continue
-		}
-		return line
-	}
-	return ""
-}
-
 // StringToLines breaks a string in a list of strings, one for each line
 func StringToLines(s string) []string {
 	lines := strings.Split(s, "\n")
@@ -36,61 +36,6 @@ func TestReadContentsMissing(t *testing.T) {
 	assert.Equal(t, "", readContents)
 }
 
-func TestReadFirstLineMissing(t *testing.T) {
-	line, err := ReadFirstLine("aksjldfhaskjeh98dfjkahf.zjkdhfa")
-	assert.NotNil(t, err)
-	assert.Equal(t, "", line)
-}
-
-func TestReadFirstLineEmpty(t *testing.T) {
-	setup()
-	source := ""
-	filepath := testutil.WriteTextFile(temppath, "TestReadFirstLineEmpty", source)
-	line, err := ReadFirstLine(filepath)
-	assert.Nil(t, err)
-	assert.Equal(t, "", line)
-	teardown()
-}
-
-func TestReadFirstLinePrefix(t *testing.T) {
-	setup()
-	source := `// comment 1
-// comment 2
-abcd`
-	filepath := testutil.WriteTextFile(temppath, "TestReadFirstLinePrefix", source)
-	firstLine, err := ReadFirstLine(filepath)
-	assert.Nil(t, err)
-	assert.Equal(t, "abcd", firstLine)
-	teardown()
-}
-
-func TestReadFirstLineBlock(t *testing.T) {
-	setup()
-	source := `/* comment 1
- comment 2
- comment 3 */ 
-abcd`
-	filepath := testutil.WriteTextFile(temppath, "TestReadFirstLineBlock", source)
-	firstLine, err := ReadFirstLine(filepath)
-	assert.Nil(t, err)
-	assert.Equal(t, "abcd", firstLine)
-	teardown()
-}
-
-func TestReadFirstLineMixture(t *testing.T) {
-	setup()
-	source := `/* comment 1
- comment 2
- comment 3 */ 
-// another rand comment 4
-abcd`
-	filepath := testutil.WriteTextFile(temppath, "TestReadFirstLineMixture", source)
-	firstLine, err := ReadFirstLine(filepath)
-	assert.Nil(t, err)
-	assert.Equal(t, "abcd", firstLine)
-	teardown()
-}
-
 func TestCountLines(t *testing.T) {
 	source := "abcd\nefgh"
 	count, err := CountLines(source)
@@ -118,3 +63,36 @@ func TestStringToLines(t *testing.T) {
 	lines := StringToLines(source)
 	assert.Equal(t, 2, len(lines))
 }
+
+func TestReadPrefix(t *testing.T) {
+	cases := map[string]struct {
+		source           string
+		expected         string
+		expectedComplete bool
+	}{
+		"empty":              {source: "", expected: "", expectedComplete: true},
+		"shorter":            {source: "abc", expected: "abc", expectedComplete: true},
+		"exact":              {source: "abcd", expected: "abcd", expectedComplete: true},
+		"longer":             {source: "abcdefgh", expected: "abcd", expectedComplete: false},
+		"byte-order mark":    {source: "\xef\xbb\xbfab", expected: "a", expectedComplete: false},
+		"byte-order mark, 1": {source: "\xef\xbb\xbfa", expected: "a", expectedComplete: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			setup()
+			defer teardown()
+			filepath := testutil.WriteTextFile(temppath, "TestReadPrefix", tc.source)
+			prefix, complete, err := ReadPrefix(filepath, 4)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, prefix)
+			assert.Equal(t, tc.expectedComplete, complete)
+		})
+	}
+}
+
+func TestReadPrefixMissing(t *testing.T) {
+	prefix, complete, err := ReadPrefix("aksjldfhaskjeh98dfjkahf.zjkdhfa", 4)
+	assert.NotNil(t, err)
+	assert.False(t, complete)
+	assert.Equal(t, "", prefix)
+}
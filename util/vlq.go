@@ -0,0 +1,167 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+)
+
+const base64Map = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/="
+
+func byteToInt(b byte) int {
+	switch {
+	case b >= 'A' && b <= 'Z':
+		return int(b - 'A')
+	case b >= 'a' && b <= 'z':
+		return int(b - 'a' + 26)
+	case b >= '0' && b <= '9':
+		return int(b - '0' + 52)
+	case b == '+':
+		return 62
+	case b == '/':
+		return 63
+	case b == '=':
+		return 64
+	default:
+		panic(fmt.Sprintf("byteToInt received byte out of range: %c", b))
+	}
+}
+
+func intToByte(i int) byte {
+	if i >= 0 && i <= 64 {
+		return base64Map[i]
+	}
+
+	panic(fmt.Sprintf("intToByte received int out of range: %d", i))
+}
+
+// DecodeVLQ decodes a base-64 VLQ string, e.g. a source map segment, to a list of integers
+func DecodeVLQ(s string) []int {
+	result := make([]int, 0, 4)
+	shift := uint(0)
+	value := 0
+
+	for _, b := range s {
+		integer := byteToInt(byte(b))
+
+		hasContinuationBit := (integer & 32) > 0
+
+		integer &= 31
+		value += integer << shift
+
+		if hasContinuationBit {
+			shift += 5
+		} else {
+			shouldNegate := (value & 1) > 0
+			value >>= 1
+
+			if shouldNegate {
+				result = append(result, -value)
+			} else {
+				result = append(result, value)
+			}
+
+			// reset
+			value = 0
+			shift = 0
+		}
+	}
+
+	return result
+}
+
+// EncodeVLQ encodes a list of integers as a base-64 VLQ string
+func EncodeVLQ(values []int) string {
+	result := make([]byte, 0, 8)
+	for _, n := range values {
+		result = append(result, encodeInteger(n)...)
+	}
+	return string(result)
+}
+
+func encodeInteger(n int) []byte {
+	result := make([]byte, 0, 8)
+
+	if n < 0 {
+		n = (-n << 1) | 1
+	} else {
+		n <<= 1
+	}
+
+	for {
+		clamped := n & 31
+		n >>= 5
+
+		if n > 0 {
+			clamped |= 32
+		}
+
+		result = append(result, intToByte(clamped))
+
+		if n <= 0 {
+			break
+		}
+	}
+
+	return result
+}
+
+// DecodeMappings decodes the mappings of a source map into absolute values: a list of segments for each generated line,
+// where each segment is [generated column, source, source line, source column] or [.., name], or [generated column]
+func DecodeMappings(mappings string) [][][]int {
+	lineStrings := strings.Split(mappings, ";")
+	lines := make([][][]int, len(lineStrings))
+	var running [5]int
+	for i, lineString := range lineStrings {
+		running[0] = 0 // the generated column resets for every line, the other fields run on
+		if lineString == "" {
+			continue
+		}
+		for _, segmentString := range strings.Split(lineString, ",") {
+			values := DecodeVLQ(segmentString)
+			if len(values) > len(running) {
+				values = values[:len(running)]
+			}
+			for field, value := range values {
+				running[field] += value
+				values[field] = running[field]
+			}
+			lines[i] = append(lines[i], values)
+		}
+	}
+	return lines
+}
+
+// InvalidMappings describes the first character of a mappings string which isn't base-64 or a separator, which would
+// otherwise panic when decoded.  Returns "" if the mappings are valid
+func InvalidMappings(mappings string) string {
+	for i, c := range mappings {
+		if c != ';' && c != ',' && (c == '=' || !strings.ContainsRune(base64Map, c)) {
+			return fmt.Sprintf("invalid character %q in mappings at offset %d", c, i)
+		}
+	}
+	return ""
+}
+
+// EncodeMappings encodes absolute segments, as returned by DecodeMappings, as the mappings of a source map
+func EncodeMappings(lines [][][]int) string {
+	var sb strings.Builder
+	var previous [5]int
+	for i, segments := range lines {
+		if i > 0 {
+			sb.WriteByte(';')
+		}
+		previous[0] = 0
+		for j, absolute := range segments {
+			if j > 0 {
+				sb.WriteByte(',')
+			}
+			relative := make([]int, len(absolute))
+			for field, value := range absolute {
+				relative[field] = value - previous[field]
+				previous[field] = value
+			}
+			sb.WriteString(EncodeVLQ(relative))
+		}
+	}
+	return sb.String()
+}
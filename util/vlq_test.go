@@ -0,0 +1,92 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeVLQ(t *testing.T) {
+	cases := map[string]struct {
+		vlq      string
+		expected []int
+	}{
+		"AAAC": {
+			vlq:      "AAAC",
+			expected: []int{0, 0, 0, 1},
+		},
+		"ADAA": {
+			vlq:      "ADAA",
+			expected: []int{0, -1, 0, 0},
+		},
+		"AAgBC": {
+			vlq:      "AAgBC",
+			expected: []int{0, 0, 16, 1},
+		},
+		"KAAK": {
+			vlq:      "KAAK",
+			expected: []int{5, 0, 0, 5},
+		},
+		"G9s6a8zns//+": {
+			vlq:      "G9s6aAs8BzC",
+			expected: []int{3, -439502, 0, 966, -41},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			actual := DecodeVLQ(tc.vlq)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestEncodeVLQ(t *testing.T) {
+	cases := map[string]struct {
+		expected string
+		nums     []int
+	}{
+		"AAAC": {
+			nums:     []int{0, 0, 0, 1},
+			expected: "AAAC",
+		},
+		"ADAA": {
+			nums:     []int{0, -1, 0, 0},
+			expected: "ADAA",
+		},
+		"AAgBC": {
+			nums:     []int{0, 0, 16, 1},
+			expected: "AAgBC",
+		},
+		"KAAK": {
+			nums:     []int{5, 0, 0, 5},
+			expected: "KAAK",
+		},
+		"G9s6a8zns//+": {
+			nums:     []int{3, -439502, 0, 966, -41},
+			expected: "G9s6aAs8BzC",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			actual := EncodeVLQ(tc.nums)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestInvalidMappings(t *testing.T) {
+	cases := map[string]struct {
+		mappings string
+		expected string
+	}{
+		"valid":      {"AAAA,CAAC;;AACA+/", ""},
+		"empty":      {"", ""},
+		"padding":    {"AA=A", `invalid character '=' in mappings at offset 2`},
+		"whitespace": {"AAAA;\nAACA", `invalid character '\n' in mappings at offset 5`},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, InvalidMappings(tc.mappings))
+		})
+	}
+}
@@ -36,6 +36,7 @@ func (hot *HotReloader) NotifyReload(changes *monitor.EventChangeset) {
 		if changes.HasSingleExt(".css") {
 			// css-only reload
 			seenFiles := make(map[string]bool)
+			seenStylesheets := make(map[string]bool)
 			for _, change := range changes.Changes() {
 				// dedupe: only reload each file once
 				if _, seen := seenFiles[change.AbsoluteFilepath()]; seen {
@@ -44,9 +45,15 @@ func (hot *HotReloader) NotifyReload(changes *monitor.EventChangeset) {
 
 				seenFiles[change.AbsoluteFilepath()] = true
 				if relativePath, ok := hot.workspace.ToRelativePath(change.AbsoluteFilepath()); ok {
-					if file := hot.moduleSet.FindFileByPath(relativePath); file != nil {
-						cssContent := file.RawContents().(*source.CSSFileContents).RawCSSContent()
-						hot.server.TriggerCSSReload(relativePath, cssContent)
+					// an imported partial is reloaded through the stylesheets which inline it
+					for _, file := range hot.moduleSet.FindInjectedStylesheets(relativePath) {
+						if seenStylesheets[file.ID] {
+							continue
+						}
+						seenStylesheets[file.ID] = true
+						if cssContents, ok := file.RawContents().(*source.CSSFileContents); ok {
+							hot.server.TriggerCSSReload(file.ID, cssContents.InjectedCSS())
+						}
 					}
 				}
 			}
@@ -26,6 +26,12 @@ const socketClientFilename = "SocketClient.js"
 const cssEscapePolyfillFilename = "css.escape.js"
 const webSocketServerPath = swarmVirtualPath + "/ws"
 
+// GraphPath is the URL path at which the dependency graph is served
+const GraphPath = swarmVirtualPath + "/graph"
+
+// SymbolicatePath is the URL path to which stack traces are POSTed, to rewrite their frames with the original sources
+const SymbolicatePath = swarmVirtualPath + "/symbolicate"
+
 // Server is the state of the web server
 type Server struct {
 	srv          *http.Server
@@ -33,6 +39,7 @@ type Server struct {
 	basePath     string
 	port         uint16
 	handlers     map[string]http.HandlerFunc
+	fallback     FallbackHandlerFunc
 	hub          *SocketHub
 }
 
@@ -62,6 +69,7 @@ func CreateServer(opts *ServerOptions) *Server {
 		basePath:     opts.BasePath,
 		port:         port,
 		handlers:     opts.Handlers,
+		fallback:     opts.Fallback,
 		hub:          hub,
 	}
 
@@ -100,7 +108,15 @@ func (server *Server) attachCustomHandlers(mux *http.ServeMux) {
 }
 
 func (server *Server) attachStaticFileServer(mux *http.ServeMux) http.Handler {
-	fileServer := http.FileServer(http.Dir(server.rootFilepath))
+	var fileServer http.Handler = http.FileServer(http.Dir(server.rootFilepath))
+	if server.fallback != nil {
+		staticFileServer := fileServer
+		fileServer = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !server.fallback(w, r) {
+				staticFileServer.ServeHTTP(w, r)
+			}
+		})
+	}
 	mux.Handle("/", fileServer)
 	return fileServer
 }
@@ -5,6 +5,9 @@ import (
 	"github.com/mrcrowl/swarm/config"
 )
 
+// FallbackHandlerFunc handles a request if it can, otherwise returning false to defer to the static file server
+type FallbackHandlerFunc func(w http.ResponseWriter, r *http.Request) bool
+
 // ServerOptions specifies the parameters for the web server
 type ServerOptions struct {
 	RootFilepath    string
@@ -12,6 +15,7 @@ type ServerOptions struct {
 	EnableHotReload bool
 	Handlers        map[string]http.HandlerFunc
 	BasePath        string
+	Fallback        FallbackHandlerFunc
 }
 
 // CreateServerOptions forms a server options object from various sources
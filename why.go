@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mrcrowl/swarm/util"
+)
+
+const whyCommand = "why"
+
+// runWhy explains why a file is included in the modules of a build
+func runWhy(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: swarm why <file> [build]")
+		os.Exit(1)
+	}
+
+	relativePath := args[0]
+	_, _, _, moduleSet := loadModuleSet(args[1:])
+	inclusions, err := moduleSet.Why(relativePath)
+	util.ExitIfError(err, "%s", err)
+
+	for _, inclusion := range inclusions {
+		fmt.Printf("'%s' is included in module '%s':\n", relativePath, inclusion.Module)
+		if len(inclusion.Chains) == 0 {
+			fmt.Println("   (not reachable from the module's entry points)")
+		}
+		for _, chain := range inclusion.Chains {
+			fmt.Printf("   %s\n", strings.Join(chain, " → "))
+		}
+
+		if len(inclusion.Absorbers) == 0 {
+			fmt.Println("   No other module would absorb this file if excluded.")
+		}
+		for _, absorber := range inclusion.Absorbers {
+			fmt.Printf("   Excluding '%s' from '%s' would absorb this file instead.\n", absorber, inclusion.Module)
+		}
+	}
+}